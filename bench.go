@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jsageryd/git-vanity-commit/vanity"
+)
+
+// benchCommit is the synthetic commit searched by the bench subcommand.
+const benchCommit = `tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904
+author Bench Author <bench@example.com> 1577872800 +0000
+committer Bench Committer <bench@example.com> 1577872800 +0000
+
+Benchmark
+`
+
+// benchPattern is practically never found, so that every setting runs for the
+// whole time given.
+var benchPattern = vanity.AffixPattern("0000000000000000", "")
+
+// benchTolerance is how much slower than the fastest setting a setting with
+// fewer workers may be and still be recommended.
+const benchTolerance = 0.05
+
+// benchResult is the rate of a search with a given backend and number of
+// workers.
+type benchResult struct {
+	backend string
+	workers int
+	rate    float64
+}
+
+// bench runs the bench subcommand with the given arguments.
+func bench(args []string) {
+	flags := flag.NewFlagSet("git-vanity-commit bench", flag.ExitOnError)
+
+	duration := flags.Duration("for", time.Second, "Time to run each setting for")
+	var backends, workerCounts listFlag
+	flags.Var(&backends, "backend", fmt.Sprintf("Hashing backend to run (may be repeated or comma-separated; defaults to all of %s)", strings.Join(vanity.Backends(), ", ")))
+	flags.Var(&workerCounts, "workers", "Number of concurrent workers to run (may be repeated or comma-separated; defaults to powers of two up to the number of CPUs)")
+
+	flags.Parse(args)
+
+	if flags.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected argument %q\n", flags.Arg(0))
+		fmt.Fprintln(os.Stderr)
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	if *duration <= 0 {
+		fmt.Fprintln(os.Stderr, "time to run each setting for must be positive")
+		fmt.Fprintln(os.Stderr)
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	if len(backends) == 0 {
+		backends = vanity.Backends()
+	}
+
+	for _, backend := range backends {
+		if !slices.Contains(vanity.Backends(), backend) {
+			fmt.Fprintf(os.Stderr, "unknown backend %q (available: %s)\n", backend, strings.Join(vanity.Backends(), ", "))
+			fmt.Fprintln(os.Stderr)
+			flags.Usage()
+			os.Exit(1)
+		}
+	}
+
+	var workers []int
+
+	for _, s := range workerCounts {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			fmt.Fprintf(os.Stderr, "invalid number of workers %q (must be a positive integer)\n", s)
+			fmt.Fprintln(os.Stderr)
+			flags.Usage()
+			os.Exit(1)
+		}
+		workers = append(workers, n)
+	}
+
+	if len(workers) == 0 {
+		workers = defaultWorkerCounts(runtime.NumCPU())
+	}
+
+	logFailedBackends()
+	log.Printf("Running each of %d settings for %s on %d CPUs", len(backends)*len(workers), *duration, runtime.NumCPU())
+
+	var results []benchResult
+
+	for _, backend := range backends {
+		for _, n := range workers {
+			rate, err := benchRate(backend, n, *duration)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			log.Printf("Tested %s commits per second with %s and %d concurrent workers", thousandSeparate(int(rate)), backend, n)
+
+			results = append(results, benchResult{backend: backend, workers: n, rate: rate})
+		}
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Backend\tWorkers\tCommits/s\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t\n", r.backend, r.workers, thousandSeparate(int(r.rate)))
+	}
+	tw.Flush()
+
+	rec := recommend(results)
+
+	fmt.Printf("\nRecommended: %s (%s commits per second with %s)\n", recommendedFlags(rec), thousandSeparate(int(rec.rate)), rec.backend)
+}
+
+// recommendedFlags returns the flags of search and work giving the setting of
+// the result, leaving out the backend if it is the default one.
+func recommendedFlags(r benchResult) string {
+	flags := fmt.Sprintf("-workers=%d", r.workers)
+	if r.backend != vanity.Backends()[0] {
+		flags = fmt.Sprintf("-backend=%s %s", r.backend, flags)
+	}
+	return flags
+}
+
+// benchRate returns the number of commits per second tested by a search of the
+// synthetic commit with the given backend and number of workers, run for the
+// given duration.
+func benchRate(backend string, workers int, duration time.Duration) (float64, error) {
+	target, err := vanity.ParseTarget(benchPattern)
+	if err != nil {
+		return 0, err
+	}
+
+	s, err := vanity.NewSearcher(vanity.Options{
+		Commit:  []byte(benchCommit),
+		Key:     "bench",
+		Targets: []vanity.Target{target},
+		Workers: workers,
+		Backend: backend,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+
+	start := time.Now()
+
+	if _, err := s.Search(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		return 0, fmt.Errorf("benchmark search ended early: %v", err)
+	}
+
+	return float64(s.Tested()) / time.Since(start).Seconds(), nil
+}
+
+// defaultWorkerCounts returns the powers of two below the number of CPUs, and
+// the number of CPUs itself.
+func defaultWorkerCounts(cpus int) []int {
+	var counts []int
+	for n := 1; n < cpus; n *= 2 {
+		counts = append(counts, n)
+	}
+	return append(counts, max(cpus, 1))
+}
+
+// recommend returns the result with the fewest workers among those within
+// benchTolerance of the fastest, as more workers than that only take CPU time
+// from everything else.
+func recommend(results []benchResult) benchResult {
+	var fastest benchResult
+	for _, r := range results {
+		if r.rate > fastest.rate {
+			fastest = r
+		}
+	}
+
+	rec := fastest
+	for _, r := range results {
+		if r.rate >= fastest.rate*(1-benchTolerance) && (r.workers < rec.workers || r.workers == rec.workers && r.rate > rec.rate) {
+			rec = r
+		}
+	}
+
+	return rec
+}
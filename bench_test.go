@@ -0,0 +1,65 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/jsageryd/git-vanity-commit/vanity"
+)
+
+func TestDefaultWorkerCounts(t *testing.T) {
+	for n, tc := range []struct {
+		cpus int
+		want []int
+	}{
+		{0, []int{1}},
+		{1, []int{1}},
+		{2, []int{1, 2}},
+		{6, []int{1, 2, 4, 6}},
+		{8, []int{1, 2, 4, 8}},
+	} {
+		if got := defaultWorkerCounts(tc.cpus); !slices.Equal(got, tc.want) {
+			t.Errorf("[%d] defaultWorkerCounts(%d) = %d, want %d", n, tc.cpus, got, tc.want)
+		}
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	for n, tc := range []struct {
+		results []benchResult
+		want    benchResult
+	}{
+		{
+			results: []benchResult{{"avx2", 1, 10}, {"avx2", 2, 20}, {"avx2", 4, 40}},
+			want:    benchResult{"avx2", 4, 40},
+		},
+		{
+			results: []benchResult{{"avx2", 1, 10}, {"avx2", 2, 20}, {"avx2", 4, 20.5}},
+			want:    benchResult{"avx2", 2, 20},
+		},
+		{
+			results: []benchResult{{"scalar", 2, 10}, {"avx2", 2, 30}, {"avx2", 4, 29}},
+			want:    benchResult{"avx2", 2, 30},
+		},
+		{
+			results: []benchResult{{"scalar", 4, 100}, {"avx2", 4, 99}, {"avx2", 8, 101}},
+			want:    benchResult{"scalar", 4, 100},
+		},
+	} {
+		if got := recommend(tc.results); got != tc.want {
+			t.Errorf("[%d] recommend(%v) = %v, want %v", n, tc.results, got, tc.want)
+		}
+	}
+}
+
+func TestRecommendedFlags(t *testing.T) {
+	def := vanity.Backends()[0]
+
+	if got, want := recommendedFlags(benchResult{def, 4, 40}), "-workers=4"; got != want {
+		t.Errorf("recommendedFlags with the default backend = %q, want %q", got, want)
+	}
+
+	if got, want := recommendedFlags(benchResult{"generic", 4, 40}), "-backend=generic -workers=4"; got != want {
+		t.Errorf("recommendedFlags with the generic backend = %q, want %q", got, want)
+	}
+}
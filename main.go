@@ -1,503 +1,1163 @@
 package main
 
 import (
-	"bytes"
-	"crypto/sha1"
-	"encoding/binary"
-	"encoding/hex"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"hash"
 	"io"
+	"io/fs"
 	"log"
 	"math"
 	"os"
-	"os/exec"
-	"regexp"
-	"runtime"
+	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
+	"syscall"
 	"time"
-	"unsafe"
-)
 
-var invalidKey = regexp.MustCompile(`^(commit|tree|parent|author|committer|encoding)\b|[^a-zA-Z0-9]`).MatchString
-var validPrefix = regexp.MustCompile("^[0-9a-f]{1,40}$").MatchString
+	"github.com/jsageryd/git-vanity-commit/vanity"
+)
 
 func main() {
 	log.SetFlags(log.Ltime | log.Lmsgprefix)
 	log.SetPrefix("| ")
 
-	commit := flag.String("commit", "HEAD", "Starting point")
-	prefix := flag.String("prefix", "", "Desired hash prefix (mandatory)")
-	key := flag.String("key", "", "Key used in the commit header (defaults to the prefix)")
-	reset := flag.Bool("reset", false, "If set, reset to the new commit (implies -write)")
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		bench(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "work" {
+		work(os.Args[2:])
+		return
+	}
+
+	// serve runs the search as usual, but by handing out ranges to workers
+	// started with work instead of searching locally
+	serve := len(os.Args) > 1 && os.Args[1] == "serve"
+
+	commit := flag.String("commit", "HEAD", "Starting point, a commit, an annotated tag, a blob or a tree")
+	var prefixes, suffixes, patterns listFlag
+	flag.Var(&prefixes, "prefix", "Desired hash prefix (may be repeated or comma-separated)")
+	flag.Var(&suffixes, "suffix", "Desired hash suffix (may be repeated or comma-separated)")
+	flag.Var(&patterns, "pattern", "Desired hash as 40 hex digits (64 in SHA-256 repositories), with '.' or 'x' matching any digit (may be repeated or comma-separated)")
+	key := flag.String("key", "", "Key used in the commit header (defaults to the fixed digits of the first prefix, suffix or pattern)")
+	nonce := flag.String("nonce-in", vanity.NonceHeader, "Where to put the number: \"header\" for a commit header with the key, \"trailer\" for a Vanity-Nonce trailer of the commit message, \"whitespace\" for spaces and tabs at the end of the commit message, or \"timestamp\" to move the committer timestamp instead")
+	encoding := flag.String("nonce-encoding", vanity.EncodingDecimal, "How to write the number in a header or trailer: \"decimal\", \"hex\", \"base36\" or \"base62\"")
+	width := flag.Int("nonce-width", 0, "Number of digits to write the number with, padded with zeros, so that the commit keeps its size (defaults to a growing number for decimal and whitespace, and room for 2^40 iterations otherwise)")
+	line := flag.String("blob-line", vanity.DefaultLine, "Template of the line holding the number at the end of a blob, with {n} where the number goes")
+	timeWindow := flag.Duration("time-window", time.Hour, "How far either side of its value to move the committer timestamp (with -nonce-in timestamp)")
+	timeAuthor := flag.Bool("time-author", false, "Also move the author timestamp within the window (with -nonce-in timestamp)")
+	timeWhitespace := flag.Bool("time-whitespace", false, "Go on with spaces and tabs at the end of the commit message once the window has been searched (with -nonce-in timestamp)")
+	reset := flag.Bool("reset", false, "If set, reset to the new commit, or point the tag at the new tag object (implies -write)")
 	write := flag.Bool("write", false, "If set, write the new commit to the repository (hash-object -w)")
 	printHash := flag.Bool("print", false, "Print the commit hash found to stdout")
 	quiet := flag.Bool("quiet", false, "Suppress log output")
 	startN := flag.Int("start", 0, "Iteration to start from")
+	endN := flag.Int("end", 0, "Iteration to stop before, exiting with status 3 if no hash is found below it")
+	best := flag.String("best", "", "Instead of a target, find the hash with the most leading zero bits (\"zeros\") or the longest run of the given leading hex digit")
+	budget := flag.Duration("for", 0, "Time to search for the best hash (with -best)")
+	statePath := flag.String("state", "", "File to save search progress to, resuming from it if it exists")
+	showProgress := flag.Bool("progress", false, "Show progress while searching")
+	workers := flag.Int("workers", 0, "Number of concurrent workers (defaults to one per CPU)")
+	backend := flag.String("backend", "", "Hashing backend to use, one of those run by bench (defaults to the fastest that works on this CPU)")
+	cpuPercent := flag.Int("cpu-percent", 100, "Percentage of the time of a CPU each worker may use, sleeping in between")
+	nice := flag.Int("nice", 0, niceUsage)
+	format := flag.String("format", "text", "Output format: \"text\", or \"json\" to print the result as JSON to stdout (and progress too, with -progress)")
+
+	var listen *string
+	var chunk *int
+
+	if serve {
+		listen = flag.String("listen", "", "Address to listen for workers on, as in :8080")
+		chunk = flag.Int("chunk", 100_000_000, "Number of iterations to hand out to a worker at a time")
+		flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
 
-	flag.Parse()
+	if len(prefixes) == 0 && len(suffixes) == 0 && len(patterns) == 0 && *best == "" {
+		fmt.Fprintln(os.Stderr, "missing prefix, suffix, pattern or best")
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
 
-	if *prefix == "" {
-		fmt.Fprintln(os.Stderr, "missing prefix")
+	if *best != "" && (len(prefixes) > 0 || len(suffixes) > 0 || len(patterns) > 0) {
+		fmt.Fprintln(os.Stderr, "best cannot be combined with prefix, suffix or pattern")
 		fmt.Fprintln(os.Stderr)
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	if !validPrefix(*prefix) {
-		fmt.Fprintln(os.Stderr, "invalid prefix (must be lowercase hex)")
+	var s *vanity.Scorer
+
+	if *best != "" {
+		var err error
+		if s, err = vanity.NewScorer(*best); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr)
+			flag.Usage()
+			os.Exit(1)
+		}
+	}
+
+	if *best != "" && *budget <= 0 {
+		fmt.Fprintln(os.Stderr, "best requires a positive time to search for")
 		fmt.Fprintln(os.Stderr)
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	if *best != "" && *statePath != "" {
+		fmt.Fprintln(os.Stderr, "state cannot be combined with best")
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if serve && *listen == "" {
+		fmt.Fprintln(os.Stderr, "missing address to listen on")
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if serve && *best != "" {
+		fmt.Fprintln(os.Stderr, "best cannot be combined with serve")
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if serve && (*workers != 0 || *cpuPercent != 100) {
+		fmt.Fprintln(os.Stderr, "workers and cpu-percent are set on the workers with serve")
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *format != "text" && *format != "json" {
+		fmt.Fprintln(os.Stderr, "invalid format (must be \"text\" or \"json\")")
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *format == "json" && *printHash {
+		fmt.Fprintln(os.Stderr, "print cannot be combined with json format")
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *best == "" && *budget != 0 {
+		fmt.Fprintln(os.Stderr, "time to search for is only used with best")
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if len(patterns) > 0 && (len(prefixes) > 0 || len(suffixes) > 0) {
+		fmt.Fprintln(os.Stderr, "pattern cannot be combined with prefix or suffix")
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	for _, prefix := range prefixes {
+		if !vanity.ValidPrefix(prefix) {
+			fmt.Fprintln(os.Stderr, "invalid prefix (must be lowercase hex)")
+			fmt.Fprintln(os.Stderr)
+			flag.Usage()
+			os.Exit(1)
+		}
+	}
+
+	for _, suffix := range suffixes {
+		if !vanity.ValidPrefix(suffix) {
+			fmt.Fprintln(os.Stderr, "invalid suffix (must be lowercase hex)")
+			fmt.Fprintln(os.Stderr)
+			flag.Usage()
+			os.Exit(1)
+		}
+	}
+
+	// the length of the hashes depends on the object format of the repository
+	objectFormat := vanity.ObjectFormat()
+
+	digits := vanity.HashDigits(objectFormat)
+
+	// descriptions describe each pattern searched for, for logging
+	var descriptions []string
+
+	if len(patterns) > 0 {
+		for _, pattern := range patterns {
+			descriptions = append(descriptions, fmt.Sprintf("matching %q", pattern))
+		}
+	} else if *best == "" {
+		if len(prefixes) == 0 {
+			prefixes = listFlag{""}
+		}
+
+		if len(suffixes) == 0 {
+			suffixes = listFlag{""}
+		}
+
+		for _, prefix := range prefixes {
+			for _, suffix := range suffixes {
+				if len(prefix)+len(suffix) > digits {
+					fmt.Fprintln(os.Stderr, "prefix and suffix together must not be longer than the hash")
+					fmt.Fprintln(os.Stderr)
+					flag.Usage()
+					os.Exit(1)
+				}
+
+				patterns = append(patterns, vanity.AffixPatternFor(objectFormat, prefix, suffix))
+
+				switch {
+				case suffix == "":
+					descriptions = append(descriptions, fmt.Sprintf("prefixed %q", prefix))
+				case prefix == "":
+					descriptions = append(descriptions, fmt.Sprintf("suffixed %q", suffix))
+				default:
+					descriptions = append(descriptions, fmt.Sprintf("prefixed %q and suffixed %q", prefix, suffix))
+				}
+			}
+		}
+	}
+
+	var targets []vanity.Target
+
+	for _, pattern := range patterns {
+		t, err := vanity.ParseTarget(pattern)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr)
+			flag.Usage()
+			os.Exit(1)
+		}
+
+		if len(pattern) != digits {
+			fmt.Fprintf(os.Stderr, "pattern must be %d characters in a %s repository\n", digits, objectFormat)
+			fmt.Fprintln(os.Stderr)
+			flag.Usage()
+			os.Exit(1)
+		}
+
+		targets = append(targets, t)
+	}
+
+	if *key == "" && len(patterns) > 0 {
+		*key = strings.NewReplacer(".", "", "x", "").Replace(patterns[0])
+	}
+
 	if *key == "" {
-		*key = *prefix
+		*key = "vanity"
 	}
 
-	if invalidKey(*key) {
+	if !vanity.ValidKey(*key) {
 		fmt.Fprintln(os.Stderr, "invalid key")
 		fmt.Fprintln(os.Stderr)
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	if !slices.Contains(vanity.Nonces(), *nonce) {
+		fmt.Fprintf(os.Stderr, "unknown nonce %q (available: %s)\n", *nonce, strings.Join(vanity.Nonces(), ", "))
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if !slices.Contains(vanity.Encodings(), *encoding) {
+		fmt.Fprintf(os.Stderr, "unknown encoding %q (available: %s)\n", *encoding, strings.Join(vanity.Encodings(), ", "))
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *encoding != vanity.EncodingDecimal && (*nonce == vanity.NonceWhitespace || *nonce == vanity.NonceTimestamp) {
+		fmt.Fprintf(os.Stderr, "encoding cannot be combined with -nonce-in %s\n", *nonce)
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *width < 0 {
+		fmt.Fprintln(os.Stderr, "nonce width must be positive")
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *width > 0 && *nonce == vanity.NonceTimestamp {
+		fmt.Fprintln(os.Stderr, "nonce width cannot be combined with -nonce-in timestamp")
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *nonce == vanity.NonceTimestamp && *timeWindow < time.Second {
+		fmt.Fprintln(os.Stderr, "time window must be at least a second")
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
 	if *startN < 0 {
 		fmt.Fprintln(os.Stderr, "starting iteration must be positive")
 		os.Exit(1)
 	}
 
+	if *endN != 0 && *endN <= *startN {
+		fmt.Fprintln(os.Stderr, "ending iteration must be above the starting iteration")
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *endN != 0 && *best != "" {
+		fmt.Fprintln(os.Stderr, "end cannot be combined with best")
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *workers < 0 {
+		fmt.Fprintln(os.Stderr, "number of workers must be positive")
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *backend != "" && !slices.Contains(vanity.Backends(), *backend) {
+		fmt.Fprintf(os.Stderr, "unknown backend %q (available: %s)\n", *backend, strings.Join(vanity.Backends(), ", "))
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *cpuPercent < 1 || *cpuPercent > 100 {
+		fmt.Fprintln(os.Stderr, "CPU percentage must be between 1 and 100")
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if err := checkNice(*nice); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr)
+		flag.Usage()
+		os.Exit(1)
+	}
+
 	if *quiet {
 		log.SetOutput(io.Discard)
 	}
 
-	commitData := fetchCommit(*commit)
+	typ, commitData, err := vanity.FetchObject(*commit)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// kind is the type of the object as written at the start of a log line,
+	// and stamp the header whose timestamp -nonce-in timestamp moves
+	kind, stamp := "Commit", "committer"
 
-	log.Printf("Using commit at %s (%s)", *commit, revParseShort(*commit))
-	log.Printf("Finding hash prefixed %q", *prefix)
+	// tagRef is the ref -reset moves, as given by -commit rather than as
+	// named in the tag object
+	var tagRef string
+
+	switch typ {
+	case vanity.TypeTag:
+		kind, stamp = "Tag", "tagger"
+
+		if *reset {
+			if tagRef, err = vanity.TagRef(*commit); err != nil {
+				log.Fatalf("cannot reset: %v", err)
+			}
+		}
+
+		// git allows no extra headers in a tag, so the number goes in a
+		// trailer unless -nonce-in puts it elsewhere
+		if *nonce == vanity.NonceHeader {
+			flag.Visit(func(f *flag.Flag) {
+				if f.Name == "nonce-in" {
+					log.Fatal("cannot use -nonce-in header with a tag, as git allows no extra headers in tags")
+				}
+			})
+			*nonce = vanity.NonceTrailer
+		}
+	case vanity.TypeBlob, vanity.TypeTree:
+		kind = strings.ToUpper(typ[:1]) + typ[1:]
+
+		if *reset {
+			log.Fatalf("cannot reset to a %s; use -write", typ)
+		}
+
+		// blobs and trees have a place of their own for the number, which
+		// -nonce-in can only be set to contradict
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "nonce-in" {
+				log.Fatalf("cannot use -nonce-in with a %s", typ)
+			}
+		})
+		*nonce = ""
+	}
+
+	commitID, err := vanity.RevParse(*commit)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	shortID, err := vanity.RevParseShort(*commit)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Using %s at %s (%s)", typ, *commit, shortID)
+	logFailedBackends()
+	if objectFormat == vanity.FormatSHA256 {
+		log.Print("Hashing with SHA-256, as the repository uses it")
+	}
+	if *best != "" {
+		log.Printf("Finding hash with the most %s in %s", s.Unit(), *budget)
+	} else {
+		log.Printf("Finding hash %s", strings.Join(descriptions, " or "))
+	}
 
 	ts := thousandSeparate
 
-	log.Printf("Commit size %s bytes", ts(len(commitData)))
+	log.Printf("%s size %s bytes", kind, ts(len(commitData)))
+
+	switch {
+	case typ == vanity.TypeBlob:
+		log.Printf("Putting the number in a line at the end of the blob, as in %q", *line)
+	case typ == vanity.TypeTree:
+		log.Print("Putting the number in the name of an extra entry of the tree")
+	}
+
+	switch *nonce {
+	case vanity.NonceTrailer:
+		log.Print("Putting the number in a trailer of the commit message")
+	case vanity.NonceWhitespace:
+		log.Print("Putting the number in whitespace at the end of the commit message")
+	case vanity.NonceTimestamp:
+		log.Printf("Moving the %s timestamp by up to %s either way", stamp, *timeWindow)
+	}
 
 	if *startN > 0 {
 		log.Printf("Starting at iteration %d", *startN)
 	}
 
-	start := time.Now()
+	if *endN > 0 {
+		log.Printf("Stopping before iteration %d", *endN)
+	}
 
-	hash, iteration, newCommit, ok := find(*prefix, *key, *startN, commitData)
-	if !ok {
-		log.Println("No hash found")
-		os.Exit(1)
+	opts := vanity.Options{
+		Commit:     commitData,
+		Type:       typ,
+		Line:       *line,
+		Format:     objectFormat,
+		Key:        *key,
+		Nonce:      *nonce,
+		Encoding:   *encoding,
+		Width:      *width,
+		Targets:    targets,
+		Scorer:     s,
+		Start:      *startN,
+		End:        *endN,
+		Workers:    *workers,
+		Backend:    *backend,
+		CPUPercent: *cpuPercent,
+		Improved: func(r vanity.Result) {
+			log.Printf("Best so far %s (%s, iteration %d)", r.Hash, s.Describe(r.Score), r.Iteration)
+		},
 	}
 
-	duration := time.Since(start)
+	if *nonce == vanity.NonceTimestamp {
+		opts.TimeWindow, opts.TimeAuthor, opts.TimeWhitespace = *timeWindow, *timeAuthor, *timeWhitespace
+	}
 
-	log.Printf("Tested %s commits at %s commits per second", ts((iteration - *startN + 1)), ts(int(float64(iteration-*startN+1)/duration.Seconds())))
-	log.Printf("Found %s (iteration %d, %s)", hash, iteration, duration.Round(time.Millisecond))
+	var state *searchState
+
+	if *statePath != "" {
+		state = &searchState{
+			Commit:         commitID,
+			Key:            *key,
+			Nonce:          *nonce,
+			Line:           *line,
+			Encoding:       *encoding,
+			Width:          *width,
+			TimeWindow:     opts.TimeWindow,
+			TimeAuthor:     opts.TimeAuthor,
+			TimeWhitespace: opts.TimeWhitespace,
+			Targets:        patterns,
+		}
 
-	if *printHash {
-		fmt.Println(hash)
+		switch saved, err := loadState(*statePath); {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			log.Fatalf("error reading state file: %v", err)
+		case !saved.sameSearch(state):
+			log.Fatalf("state file %s is for a different commit, key, nonce or target; remove it to start over", *statePath)
+		case *startN != 0:
+			log.Fatal("cannot use -start when resuming from a state file")
+		default:
+			opts.Resume = saved.Next
+		}
 	}
 
-	if *write || *reset {
-		writtenHash := writeCommit(newCommit)
+	var searcher search
 
-		log.Println("Commit object written")
+	var workersDescription string
 
-		if hash != writtenHash {
-			fmt.Printf("hash mismatch: git-vanity-commit %q vs. hash-object output %q\n", hash, writtenHash)
-			os.Exit(1)
+	if serve {
+		c, err := newCoordinator(opts, patterns, *listen, *chunk)
+		if err != nil {
+			log.Fatal(err)
+		}
+		searcher = c
+		workersDescription = fmt.Sprintf("Handing out %s iterations at a time to workers connecting to %s", ts(*chunk), c.addr())
+	} else {
+		local, err := vanity.NewSearcher(opts)
+		if err != nil {
+			log.Fatal(err)
 		}
+		searcher = local
+		workersDescription = fmt.Sprintf("Using %d concurrent workers", local.Workers())
 	}
 
-	if *reset {
-		resetTo(hash)
-		log.Printf("HEAD is now at %s", hash)
+	if opts.Resume != nil {
+		log.Printf("Resuming from state file %s at iteration %d", *statePath, searcher.Next())
 	}
-}
 
-func revParseShort(rev string) string {
-	out, err := exec.Command("git", "rev-parse", "--short=12", "--verify", rev).Output()
-	if err != nil {
-		if eErr, ok := err.(*exec.ExitError); ok {
-			log.Fatalf("error parsing revision; git says %v", string(eErr.Stderr))
-		} else {
-			log.Fatalf("error parsing revision: %v", err)
-		}
+	var stopCheckpoints func()
+
+	if state != nil {
+		stopCheckpoints = checkpoint(state, searcher, *statePath)
 	}
-	return string(bytes.TrimSpace(out))
-}
 
-func fetchCommit(ref string) []byte {
-	shortRef := revParseShort(ref)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	out, err := exec.Command("git", "cat-file", "-t", ref).Output()
-	if err != nil {
-		if eErr, ok := err.(*exec.ExitError); ok {
-			log.Fatalf("error reading object type; git says %v", string(eErr.Stderr))
-		} else {
-			log.Fatalf("error reading object type: %v", err)
-		}
-	}
-	if got, want := strings.TrimSpace(string(out)), "commit"; got != want {
-		log.Fatalf("%s is a %s object; expected a commit", shortRef, got)
+	if *best != "" {
+		ctx, cancel = context.WithTimeout(ctx, *budget)
+		defer cancel()
 	}
 
-	out, err = exec.Command("git", "cat-file", "commit", ref).Output()
-	if err != nil {
-		if eErr, ok := err.(*exec.ExitError); ok {
-			log.Fatalf("error reading commit; git says %v", string(eErr.Stderr))
+	interrupted := make(chan struct{})
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-sigs
+		signal.Stop(sigs)
+		log.Println("Interrupted; stopping (interrupt again to exit immediately)")
+		close(interrupted)
+		cancel()
+	}()
+
+	var report func(progress)
+
+	var status *statusLine
+
+	if *showProgress && *format == "json" {
+		report = func(pr progress) {
+			printJSON(jsonProgress{
+				Event:          "progress",
+				Tested:         pr.tested,
+				Rate:           pr.rate,
+				ElapsedSeconds: pr.elapsed.Seconds(),
+				Chance:         pr.chance,
+			})
+		}
+	} else if *showProgress && !*quiet {
+		describe := func(pr progress) string {
+			line := fmt.Sprintf("Tested %s commits at %s commits per second (%s elapsed", ts(pr.tested), ts(int(pr.rate)), pr.elapsed.Round(time.Second))
+			switch {
+			case pr.chance >= 0.001:
+				line += fmt.Sprintf(", %.1f%% chance of a match by now", 100*pr.chance)
+			case pr.chance > 0:
+				line += ", <0.1% chance of a match by now"
+			}
+			return line + ")"
+		}
+
+		if isTerminal(os.Stderr) {
+			status = &statusLine{w: os.Stderr}
+			log.SetOutput(status)
+
+			report = func(pr progress) {
+				status.set(time.Now().Format("15:04:05 ") + log.Prefix() + describe(pr))
+			}
 		} else {
-			log.Fatalf("error reading commit: %v", err)
+			report = func(pr progress) {
+				log.Print(describe(pr))
+			}
 		}
 	}
-	return out
-}
 
-func find(hashPrefix, header string, startN int, commit []byte) (hash string, iteration int, newCommit []byte, ok bool) {
-	const pollInterval = 256
+	if *nice > 0 {
+		if err := setNice(*nice); err != nil {
+			log.Fatalf("error setting niceness: %v", err)
+		}
+		log.Printf("Running at niceness %d", *nice)
+	}
 
-	done := make(chan struct{})
+	log.Print(workersDescription)
 
-	type res struct {
-		hash string
-		n    int
-		b    []byte
+	if *cpuPercent < 100 {
+		log.Printf("Limiting each worker to %d%% of a CPU", *cpuPercent)
 	}
 
-	found := make(chan res)
+	start := time.Now()
 
-	var firstN int
+	finished := make(chan struct{})
 
 	var wg sync.WaitGroup
 
-	prefixWords, prefixMask, prefixLen := hashPrefixWords(hashPrefix)
+	if report != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reportProgress(searcher, report, finished)
+		}()
+	}
 
-	var totalCount atomic.Int64
+	if *best == "" {
+		warnCoverage(searcher, *nonce)
 
-	work := func(offset, stepSize int) {
-		defer wg.Done()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logEstimate(searcher, finished)
+		}()
+	}
 
-		h := sha1.New()
-		hashState := sha1State(h)
+	res, err := searcher.Search(ctx)
 
-		head, tail := headTail(commit)
-		head = trimHeader(head, header)
+	close(finished)
+	wg.Wait()
 
-		commitHeaderBytes := []byte("commit ")
-		headerBytes := []byte("\n" + header + " ")
-		nullByte := []byte{0x00}
+	signal.Stop(sigs)
 
-		var nBytes []byte
-		var commitSizeBytes []byte
+	duration := time.Since(start)
 
-		var lastSum [5]uint32
+	tested := searcher.Tested()
 
-		var nBytesTailAndPadding []byte
+	if status != nil {
+		status.clear()
+		log.SetOutput(os.Stderr)
+	}
 
-		var count int
+	if stopCheckpoints != nil {
+		stopCheckpoints()
 
-		for n := offset; n >= 0; n += stepSize {
-			if !addToDigits(nBytes, stepSize) {
-				nBytes = strconv.AppendInt(nBytes[:0], int64(n), 10)
-				commitSize := len(head) + len(tail) + len(header) + 1 + len(nBytes) + 1
-				h.Reset()
-				commitSizeBytes = strconv.AppendInt(commitSizeBytes[:0], int64(commitSize), 10)
-				h.Write(commitHeaderBytes)
-				h.Write(commitSizeBytes)
-				h.Write(nullByte)
-				h.Write(head)
-				h.Write(headerBytes)
-				lastSum = hashState.h
+		if err == nil {
+			if err := os.Remove(*statePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log.Printf("error removing state file: %v", err)
+			}
+		} else {
+			log.Printf("State saved to %s", *statePath)
+		}
+	}
 
-				objectSize := len(commitHeaderBytes) + len(commitSizeBytes) + len(nullByte) + commitSize
+	result := jsonResult{
+		Event:           "result",
+		Key:             *key,
+		Nonce:           *nonce,
+		Found:           err == nil,
+		Tested:          tested,
+		Rate:            float64(tested) / duration.Seconds(),
+		DurationSeconds: duration.Seconds(),
+	}
 
-				nOffset := hashState.nx
-				nBytesTailAndPadding = paddedNSizeTailBlock(hashState.x[:nOffset], len(nBytes), tail, objectSize)
-				copy(nBytesTailAndPadding[nOffset:], nBytes)
-				nBytes = nBytesTailAndPadding[nOffset : nOffset+len(nBytes)]
+	if *format == "json" {
+		result.Commit = commitID
+	}
 
-				hashState.nx = 0
+	if err != nil {
+		select {
+		case <-interrupted:
+			log.Printf("Tested %s commits at %s commits per second", ts(tested), ts(int(float64(tested)/duration.Seconds())))
+			log.Print(continueHint(*statePath, searcher.Next(), *endN))
+
+			if *format == "json" {
+				start := searcher.Next()
+				result.Interrupted = true
+				result.Start = &start
+				printJSON(result)
 			}
-			hashState.h = lastSum
-			h.Write(nBytesTailAndPadding)
 
-			if hashState.h[0]&prefixMask[0] == prefixWords[0] && match(&hashState.h, &prefixWords, &prefixMask, prefixLen) {
-				var sum [sha1.Size]byte
-				for i, w := range hashState.h {
-					binary.BigEndian.PutUint32(sum[i*4:], w)
-				}
+			os.Exit(130)
+		default:
+		}
 
-				buf := new(bytes.Buffer)
-				buf.Write(head)
-				buf.Write(headerBytes)
-				buf.Write(nBytes)
-				buf.Write(tail)
-				found <- res{hex.EncodeToString(sum[:]), n, buf.Bytes()}
-				return
+		if errors.Is(err, vanity.ErrExhausted) {
+			if *nonce == vanity.NonceTimestamp && *endN == 0 {
+				log.Printf("No hash found within %s of the %s timestamp", *timeWindow, stamp)
+			} else {
+				log.Printf("No hash found below iteration %d", searcher.End())
+			}
+
+			if *format == "json" {
+				result.Exhausted = true
+				printJSON(result)
 			}
 
-			count++
+			// a status of its own, for scripts sharding a search by hand to
+			// tell an exhausted range from an error
+			os.Exit(3)
+		}
 
-			if count >= pollInterval {
-				totalCount.Add(int64(count))
-				count = 0
+		log.Println("No hash found")
 
-				select {
-				case <-done:
-					if n > firstN {
-						return
-					}
-				default:
-				}
-			}
+		if *format == "json" {
+			result.Error = err.Error()
+			printJSON(result)
 		}
+
+		os.Exit(1)
 	}
 
-	workers := runtime.GOMAXPROCS(0)
+	result.Hash = res.Hash
+	result.Iteration = &res.Iteration
 
-	if numCPU := runtime.NumCPU(); workers > numCPU {
-		workers = numCPU
+	if *best != "" {
+		result.Score = &res.Score
+	} else {
+		result.Matched = patterns[res.Matched]
 	}
 
-	log.Printf("Using %d concurrent workers", workers)
+	log.Printf("Tested %s commits at %s commits per second", ts(tested), ts(int(float64(tested)/duration.Seconds())))
+	log.Printf("Found %s (iteration %d, %s)", res.Hash, res.Iteration, duration.Round(time.Millisecond))
 
-	for i := range workers {
-		offset := startN + i
-		if offset < 0 {
-			break
-		}
-		wg.Add(1)
-		go work(offset, workers)
+	if *best != "" {
+		log.Printf("Best hash has %s", s.Describe(res.Score))
 	}
 
-	go func() {
-		wg.Wait()
-		close(found)
-	}()
-
-	go func() {
-		time.Sleep(100 * time.Millisecond)
+	if len(targets) > 1 {
+		log.Printf("Matched hash %s", descriptions[res.Matched])
+	}
 
-		startTotal := totalCount.Load()
-		start := time.Now()
+	if *printHash {
+		fmt.Println(res.Hash)
+	}
 
-		select {
-		case <-done:
-			return
-		case <-time.After(100 * time.Millisecond):
+	// fail ends a search whose hash was found but could not be put to use,
+	// printing the result with the error in JSON so that scripts still get
+	// the hash
+	fail := func(err error) {
+		if *format == "json" {
+			result.Error = err.Error()
+			printJSON(result)
 		}
+		log.Fatal(err)
+	}
 
-		total := totalCount.Load() - startTotal
-		duration := time.Since(start)
+	if *write || *reset {
+		// the extra entry of a tree points to the empty blob, which may not
+		// be in the repository yet
+		if typ == vanity.TypeTree {
+			if _, err := vanity.WriteObject(vanity.TypeBlob, nil); err != nil {
+				fail(err)
+			}
+		}
 
-		if total == 0 {
-			return
+		writtenHash, err := vanity.WriteObject(typ, res.Commit)
+		if err != nil {
+			fail(err)
 		}
 
-		p10, p50, p90 := estimate(float64(total)/duration.Seconds(), len(hashPrefix))
+		log.Printf("%s object written", kind)
+
+		if res.Hash != writtenHash {
+			fail(fmt.Errorf("hash mismatch: git-vanity-commit %q vs. hash-object output %q", res.Hash, writtenHash))
+		}
 
-		const year = 60 * 60 * 24 * 365 // seconds
+		result.Written = true
+	}
 
-		if p90 > 500_000_000*year {
-			log.Println("Estimated search time is many millions of years")
-			return
+	if *reset && typ == vanity.TypeTag {
+		// the tag is only moved if nothing else has moved it since the search
+		// started
+		if err := vanity.UpdateTag(tagRef, res.Hash, commitID); err != nil {
+			fail(err)
 		}
+		log.Printf("%s is now at %s", tagRef, res.Hash)
+	} else if *reset {
+		if err := vanity.ResetTo(res.Hash); err != nil {
+			fail(err)
+		}
+		log.Printf("HEAD is now at %s", res.Hash)
+	}
 
-		log.Printf(
-			"Estimated search time <%s (10%%), <%s (50%%), <%s (90%%)",
-			roundUpHuman(p10), roundUpHuman(p50), roundUpHuman(p90),
-		)
-	}()
+	if *format == "json" {
+		result.Reset = *reset
+		printJSON(result)
+	}
+}
 
-	minRes, ok := <-found
-	firstN = minRes.n
+// jsonResult is the result of a search, printed with -format json.
+type jsonResult struct {
+	Event           string  `json:"event"`
+	Commit          string  `json:"commit"`
+	Key             string  `json:"key"`
+	Nonce           string  `json:"nonce,omitempty"`
+	Found           bool    `json:"found"`
+	Hash            string  `json:"hash,omitempty"`
+	Iteration       *int    `json:"iteration,omitempty"`
+	Matched         string  `json:"matched,omitempty"`
+	Score           *int    `json:"score,omitempty"`
+	Tested          int     `json:"tested"`
+	Rate            float64 `json:"rate"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Written         bool    `json:"written"`
+	Reset           bool    `json:"reset"`
+	Interrupted     bool    `json:"interrupted,omitempty"`
+	Exhausted       bool    `json:"exhausted,omitempty"`
+	Start           *int    `json:"start,omitempty"`
+	Error           string  `json:"error,omitempty"`
+}
 
-	close(done)
+// jsonProgress is the progress of a search, printed with -format json and
+// -progress.
+type jsonProgress struct {
+	Event          string  `json:"event"`
+	Tested         int     `json:"tested"`
+	Rate           float64 `json:"rate"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	Chance         float64 `json:"chance,omitempty"`
+}
 
-	for r := range found {
-		if r.n < minRes.n {
-			minRes = r
-		}
+// logFailedBackends logs the backends left out for hashing wrongly on this
+// CPU, as the search then falls back to a slower one.
+func logFailedBackends() {
+	for _, name := range vanity.FailedBackends() {
+		log.Printf("Not using the %s backend, as it hashes wrongly on this CPU (please report this)", name)
 	}
+}
 
-	return minRes.hash, minRes.n, minRes.b, ok
+// printJSON prints v to stdout as a single line of JSON.
+func printJSON(v any) {
+	if err := json.NewEncoder(os.Stdout).Encode(v); err != nil {
+		log.Fatalf("error writing JSON: %v", err)
+	}
 }
 
-type sha1Digest struct {
-	h   [5]uint32
-	x   [64]byte
-	nx  int
-	len uint64
+// listFlag is a flag that may be repeated, each value being a comma-separated
+// list.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
 }
 
-func sha1State(h hash.Hash) *sha1Digest {
-	type eface struct {
-		_type uintptr
-		data  unsafe.Pointer
-	}
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, strings.Split(s, ",")...)
+	return nil
+}
 
-	return (*sha1Digest)((*eface)(unsafe.Pointer(&h)).data)
+// search is a search for a hash, run locally by a vanity.Searcher or by
+// workers given ranges to search by a coordinator.
+type search interface {
+	Search(ctx context.Context) (vanity.Result, error)
+	Tested() int
+	Frontier() []int
+	Next() int
+	End() int
+	Probability() float64
 }
 
-// hashPrefixWords returns the desired hash prefix as big-endian words, the
-// mask selecting the significant bits of each, and the number of words used.
-func hashPrefixWords(hashPrefix string) (words, mask [5]uint32, n int) {
-	padded, _ := hex.DecodeString(hashPrefix + strings.Repeat("0", sha1.Size*2-len(hashPrefix)))
+const progressInterval = 2 * time.Second
+
+// progress is the progress of a running search.
+type progress struct {
+	tested  int
+	rate    float64
+	elapsed time.Duration
 
-	bits := 4 * len(hashPrefix)
+	// chance is the probability of having found a match by now, or zero if
+	// there is no target to match
+	chance float64
+}
 
-	for i := range words {
-		words[i] = binary.BigEndian.Uint32(padded[i*4:])
+// reportProgress calls report at intervals with the progress of the search
+// until finished is closed.
+func reportProgress(s search, report func(progress), finished <-chan struct{}) {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	last, lastTime := 0, start
 
-		if b := bits - 32*i; b > 0 {
-			if b > 32 {
-				b = 32
+	for {
+		select {
+		case <-finished:
+			return
+		case now := <-ticker.C:
+			total := s.Tested()
+
+			pr := progress{
+				tested:  total,
+				rate:    float64(total-last) / now.Sub(lastTime).Seconds(),
+				elapsed: now.Sub(start),
 			}
-			mask[i] = ^uint32(0) << (32 - b)
-			words[i] &= mask[i]
-			n = i + 1
+
+			if p := s.Probability(); p > 0 {
+				pr.chance = vanity.Chance(total, p)
+			}
+
+			report(pr)
+
+			last, lastTime = total, now
 		}
 	}
+}
 
-	return words, mask, n
+// statusLine writes log output to a terminal while keeping a status line
+// below it.
+type statusLine struct {
+	mu   sync.Mutex
+	w    io.Writer
+	line string
 }
 
-func match(sum, words, mask *[5]uint32, n int) bool {
-	for i := range n {
-		if sum[i]&mask[i] != words[i] {
-			return false
-		}
+func (s *statusLine) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.line != "" {
+		io.WriteString(s.w, "\r\x1b[K")
 	}
-	return true
-}
 
-// addToDigits adds step to the decimal digits in place. It reports whether the
-// result still fits in the same number of digits.
-func addToDigits(digits []byte, step int) bool {
-	carry := step
+	n, err := s.w.Write(p)
 
-	for i := len(digits) - 1; i >= 0; i-- {
-		if carry == 0 {
-			return true
-		}
-		v := int(digits[i]-'0') + carry
-		digits[i] = byte('0' + v%10)
-		carry = v / 10
+	if s.line != "" {
+		io.WriteString(s.w, s.line)
 	}
 
-	return carry == 0
+	return n, err
+}
+
+// set replaces the status line.
+func (s *statusLine) set(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	io.WriteString(s.w, "\r\x1b[K"+line)
+	s.line = line
 }
 
-// paddedNSizeTailBlock returns a buffer that starts with the given already
-// buffered bytes, leaves nLen bytes for the caller to fill in the nonce, and
-// ends with the given tail and the SHA-1 padding, on a block boundary.
-func paddedNSizeTailBlock(buffered []byte, nLen int, tail []byte, objectSize int) []byte {
-	size := len(buffered) + nLen + len(tail) + 1 + 8
+// clear removes the status line.
+func (s *statusLine) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	if r := size % sha1.BlockSize; r != 0 {
-		size += sha1.BlockSize - r
+	if s.line != "" {
+		io.WriteString(s.w, "\r\x1b[K")
 	}
+	s.line = ""
+}
 
-	block := make([]byte, size)
-	nOffset := copy(block, buffered)
-	copy(block[nOffset+nLen:], tail)
-	block[nOffset+nLen+len(tail)] = 0x80
-	binary.BigEndian.PutUint64(block[size-8:], uint64(objectSize)*8)
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
 
-	return block
+// searchState is the progress of a search, saved to a state file so that the
+// search can be resumed later.
+type searchState struct {
+	Commit string `json:"commit"`
+	Key    string `json:"key"`
+	Nonce  string `json:"nonce,omitempty"`
+	Line   string `json:"line,omitempty"`
+
+	Encoding string `json:"encoding,omitempty"`
+	Width    int    `json:"width,omitempty"`
+
+	TimeWindow     time.Duration `json:"time_window,omitempty"`
+	TimeAuthor     bool          `json:"time_author,omitempty"`
+	TimeWhitespace bool          `json:"time_whitespace,omitempty"`
+
+	Targets []string `json:"targets"`
+	Next    []int    `json:"next"`
 }
 
-func headTail(commit []byte) (head, tail []byte) {
-	idx := bytes.Index(commit, []byte("\n\n"))
-	if idx == -1 {
-		log.Fatal("cannot parse commit")
+// loadState reads the search state from the given file.
+func loadState(path string) (*searchState, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
-	return commit[:idx], commit[idx:]
+
+	var state searchState
+
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("error parsing state file: %w", err)
+	}
+
+	if len(state.Next) == 0 {
+		return nil, errors.New("error parsing state file: no iterations")
+	}
+
+	return &state, nil
 }
 
-func trimHeader(head []byte, header string) []byte {
-	idx := bytes.LastIndex(head, []byte("\n"))
-	if idx == -1 {
-		return head
+// save writes the search state to the given file, replacing it atomically.
+func (s *searchState) save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
 	}
 
-	if bytes.HasPrefix(head[idx+1:], []byte(header)) {
-		return head[:idx]
+	tmp := path + ".tmp"
+
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
 	}
 
-	return head
+	return os.Rename(tmp, path)
 }
 
-func writeCommit(commit []byte) (hash string) {
-	cmd := exec.Command("git", "hash-object", "--stdin", "-t", "commit", "-w")
+// sameSearch reports whether the state is for the same search as other, all
+// but the progress. State files from before the nonce could be chosen are for
+// the header nonce and decimal encoding.
+func (s *searchState) sameSearch(other *searchState) bool {
+	return s.Commit == other.Commit &&
+		s.Key == other.Key &&
+		cmp.Or(s.Nonce, vanity.NonceHeader) == cmp.Or(other.Nonce, vanity.NonceHeader) &&
+		cmp.Or(s.Line, vanity.DefaultLine) == cmp.Or(other.Line, vanity.DefaultLine) &&
+		cmp.Or(s.Encoding, vanity.EncodingDecimal) == cmp.Or(other.Encoding, vanity.EncodingDecimal) &&
+		s.Width == other.Width &&
+		s.TimeWindow == other.TimeWindow &&
+		s.TimeAuthor == other.TimeAuthor &&
+		s.TimeWhitespace == other.TimeWhitespace &&
+		slices.Equal(s.Targets, other.Targets)
+}
 
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		log.Fatal(err)
+const checkpointInterval = 10 * time.Second
+
+// checkpoint saves the search state with the frontier of the given searcher at
+// intervals. The returned function stops it, saving the state a final time.
+func checkpoint(state *searchState, s search, path string) (stop func()) {
+	ticker := time.NewTicker(checkpointInterval)
+
+	save := func() {
+		state.Next = s.Frontier()
+
+		if err := state.save(path); err != nil {
+			log.Printf("error saving state: %v", err)
+		}
 	}
 
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
 	go func() {
-		stdin.Write(commit)
-		stdin.Close()
-	}()
+		defer close(stopped)
 
-	out, err := cmd.Output()
-	if err != nil {
-		if eErr, ok := err.(*exec.ExitError); ok {
-			log.Fatalf("error writing object; git says %v", string(eErr.Stderr))
-		} else {
-			log.Fatalf("error writing object: %v", err)
+		for {
+			select {
+			case <-ticker.C:
+				save()
+			case <-done:
+				return
+			}
 		}
-	}
+	}()
 
-	return string(bytes.TrimSpace(out))
+	return func() {
+		ticker.Stop()
+		close(done)
+		<-stopped
+		save()
+	}
 }
 
-func resetTo(hash string) {
-	if err := exec.Command("git", "reset", hash).Run(); err != nil {
-		if eErr, ok := err.(*exec.ExitError); ok {
-			log.Fatalf("error resetting to commit; git says %v", string(eErr.Stderr))
-		} else {
-			log.Fatalf("error resettting to commit: %v", err)
-		}
+// continueHint returns how to continue an interrupted search that got to the
+// given iteration. A search with a state file continues from it, and refuses
+// -start.
+func continueHint(statePath string, next, end int) string {
+	switch {
+	case statePath != "":
+		return fmt.Sprintf("To continue, rerun with the same -state %s", statePath)
+	case end > 0:
+		return fmt.Sprintf("To continue, use -start=%d -end=%d", next, end)
+	default:
+		return fmt.Sprintf("To continue, use -start=%d", next)
 	}
 }
 
-// estimate returns percentile estimates of the time in seconds to find a match.
-func estimate(hashesPerSecond float64, prefixLength int) (p10, p50, p90 float64) {
-	if hashesPerSecond <= 0 {
-		return 0, 0, 0
+// logEstimate logs an estimate of the search time, based on the rate of the
+// searcher shortly after it has started, unless finished is closed first.
+func logEstimate(s search, finished <-chan struct{}) {
+	select {
+	case <-finished:
+		return
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	startTotal := s.Tested()
+	start := time.Now()
+
+	select {
+	case <-finished:
+		return
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	total := s.Tested() - startTotal
+	duration := time.Since(start)
+
+	if total == 0 {
+		return
 	}
 
-	// prefixSpace is the total number of possible prefixes at the given length
-	prefixSpace := math.Pow(16, float64(prefixLength))
+	p10, p50, p90 := vanity.Estimate(float64(total)/duration.Seconds(), s.Probability())
 
-	quantile := func(q float64) (seconds float64) {
-		// p is the probability of finding one match
-		p := 1 / prefixSpace
+	const year = 60 * 60 * 24 * 365 // seconds
 
-		// k is the number of attempts needed to find one match at probability q
-		//
-		// math.Log1p(-p) is the same as math.Log(1-p) but accurate for small
-		// values. It prevents 1-p from collapsing to 1.0 for longer hash prefixes,
-		// which would cause math.Log(1-p) to return 0 and k to be infinite, in turn
-		// resulting in mangled estimates.
-		k := math.Log(1-q) / math.Log1p(-p)
+	if p90 > 500_000_000*year {
+		log.Println("Estimated search time is many millions of years")
+		return
+	}
 
-		// t is the number of seconds needed to run k attempts
-		t := k / hashesPerSecond
+	log.Printf(
+		"Estimated search time <%s (10%%), <%s (50%%), <%s (90%%)",
+		roundUpHuman(p10), roundUpHuman(p50), roundUpHuman(p90),
+	)
+}
 
-		return t
+// warnCoverage warns if there are too few iterations left before the end of
+// the search for a match to be likely, as with a narrow time window.
+func warnCoverage(s search, nonce string) {
+	end, p := s.End(), s.Probability()
+	if end == math.MaxInt || p == 0 {
+		return
 	}
 
-	return quantile(0.1), quantile(0.5), quantile(0.9)
+	left := end - s.Next()
+
+	chance := vanity.Chance(left, p)
+	if chance >= 0.9 {
+		return
+	}
+
+	log.Printf("Only %s commits left to search, with a %.2g%% chance of a match", thousandSeparate(left), chance*100)
+
+	if nonce == vanity.NonceTimestamp {
+		log.Print("Widen the window with -time-window, or add -time-author or -time-whitespace")
+	}
 }
 
 func roundUpHuman(seconds float64) string {
@@ -2,335 +2,147 @@ package main
 
 import (
 	"bytes"
-	"crypto/sha1"
-	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"io"
+	"io/fs"
 	"log"
-	"math"
+	"os"
+	"path/filepath"
 	"slices"
 	"testing"
+	"time"
+
+	"github.com/jsageryd/git-vanity-commit/vanity"
 )
 
 func init() {
 	log.SetOutput(io.Discard)
 }
 
-func TestInvalidKey(t *testing.T) {
-	for n, tc := range []struct {
-		key     string
-		invalid bool
-	}{
-		{"commit", true},
-		{"tree", true},
-		{"parent", true},
-		{"author", true},
-		{"committer", true},
-		{"encoding", true},
-		{"commit ", true},
-		{"non-alphanumeric", true},
-		{"x", false},
-		{"f00", false},
-	} {
-		if got, want := invalidKey(tc.key), tc.invalid; got != want {
-			t.Errorf("[%d] invalidKey(%q) = %t, want %t", n, tc.key, got, want)
-		}
-	}
-}
+func TestSearchState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
 
-func TestValidPrefix(t *testing.T) {
-	for n, tc := range []struct {
-		prefix string
-		valid  bool
-	}{
-		{"", false},
-		{"x", false},
-		{"0", true},
-		{"f00", true},
-		{"0000000000000000000000000000000000000000", true},   // 40 chars
-		{"00000000000000000000000000000000000000000", false}, // 41 chars
-	} {
-		if got, want := validPrefix(tc.prefix), tc.valid; got != want {
-			t.Errorf("[%d] validPrefix(%q) = %t, want %t", n, tc.prefix, got, want)
-		}
+	if _, err := loadState(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("got error %v, want %v", err, fs.ErrNotExist)
 	}
-}
-
-const commit = `tree 0000000000000000000000000000000000000000
-author Author Name <author@example.com> 1577872800 +0000
-committer Committer Name <committer@example.com> 1577876400 +0100
-
-Message
-`
-
-func TestHeadTail(t *testing.T) {
-	wantHead := []byte(`tree 0000000000000000000000000000000000000000
-author Author Name <author@example.com> 1577872800 +0000
-committer Committer Name <committer@example.com> 1577876400 +0100`)
 
-	wantTail := []byte("\n\nMessage\n")
-
-	head, tail := headTail([]byte(commit))
-
-	if !bytes.Equal(head, wantHead) {
-		t.Errorf("head is %s, want %s", head, wantHead)
+	state := &searchState{
+		Commit:  "0123456789abcdef0123456789abcdef01234567",
+		Key:     "c0ffee",
+		Nonce:   vanity.NonceHeader,
+		Targets: []string{vanity.AffixPattern("c0ffee", "")},
+		Next:    []int{300, 1201, 602},
 	}
 
-	if !bytes.Equal(tail, wantTail) {
-		t.Errorf("tail is %s, want %s", tail, wantTail)
+	if err := state.save(path); err != nil {
+		t.Fatal(err)
 	}
-}
 
-func TestFind(t *testing.T) {
-	for _, tc := range []struct {
-		desc          string
-		startN        int
-		prefix        string
-		wantHash      string
-		wantIteration int
-		wantNewCommit []byte
-		wantOK        bool
-	}{
-		{
-			desc:          "Start at 0th iteration",
-			prefix:        "0",
-			startN:        0,
-			wantHash:      "034c4f788c4a7522a75e1b86ee3c24eee630e822",
-			wantIteration: 16,
-			wantNewCommit: []byte(`tree 0000000000000000000000000000000000000000
-author Author Name <author@example.com> 1577872800 +0000
-committer Committer Name <committer@example.com> 1577876400 +0100
-foo 16
+	loaded, err := loadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-Message
-`),
-			wantOK: true,
-		},
-		{
-			desc:          "Start at final iteration",
-			prefix:        "0",
-			startN:        16,
-			wantHash:      "034c4f788c4a7522a75e1b86ee3c24eee630e822",
-			wantIteration: 16,
-			wantNewCommit: []byte(`tree 0000000000000000000000000000000000000000
-author Author Name <author@example.com> 1577872800 +0000
-committer Committer Name <committer@example.com> 1577876400 +0100
-foo 16
+	if !slices.Equal(loaded.Next, state.Next) {
+		t.Errorf("next = %d, want %d", loaded.Next, state.Next)
+	}
 
-Message
-`),
-			wantOK: true,
-		},
-		{
-			desc:          "Start after would-be final iteration",
-			prefix:        "0",
-			startN:        17,
-			wantHash:      "0457314be0b9283e18224b8dfad77741d9f41cdf",
-			wantIteration: 43,
-			wantNewCommit: []byte(`tree 0000000000000000000000000000000000000000
-author Author Name <author@example.com> 1577872800 +0000
-committer Committer Name <committer@example.com> 1577876400 +0100
-foo 43
+	if !loaded.sameSearch(state) {
+		t.Errorf("loaded state is not for the same search: %+v", loaded)
+	}
 
-Message
-`),
-			wantOK: true,
-		},
-		{
-			desc:          "Start at maxint iteration",
-			prefix:        "1",
-			startN:        math.MaxInt,
-			wantHash:      "",
-			wantIteration: 0,
-			wantNewCommit: nil,
-			wantOK:        false,
-		},
+	for n, change := range []func(s *searchState){
+		func(s *searchState) { s.Commit = "1123456789abcdef0123456789abcdef01234567" },
+		func(s *searchState) { s.Key = "decade" },
+		func(s *searchState) { s.Nonce = vanity.NonceWhitespace },
+		func(s *searchState) { s.Encoding = vanity.EncodingHex },
+		func(s *searchState) { s.Width = 12 },
+		func(s *searchState) { s.Line = "# {n}" },
+		func(s *searchState) { s.Nonce, s.TimeWindow = vanity.NonceTimestamp, time.Hour },
+		func(s *searchState) { s.Nonce, s.TimeWindow, s.TimeAuthor = vanity.NonceTimestamp, time.Hour, true },
+		func(s *searchState) { s.Nonce, s.TimeWindow, s.TimeWhitespace = vanity.NonceTimestamp, time.Hour, true },
+		func(s *searchState) { s.Targets = []string{vanity.AffixPattern("decade", "")} },
+		func(s *searchState) { s.Targets = append(s.Targets, vanity.AffixPattern("decade", "")) },
 	} {
-		t.Run(tc.desc, func(t *testing.T) {
-			hash, iteration, newCommit, ok := find(tc.prefix, "foo", tc.startN, []byte(commit))
-
-			if got, want := hash, tc.wantHash; got != want {
-				t.Errorf("hash = %q, want %q", got, want)
-			}
-
-			if got, want := iteration, tc.wantIteration; got != want {
-				t.Errorf("iteration = %d, want %d", got, want)
-			}
+		other := *state
+		change(&other)
 
-			if !bytes.Equal(newCommit, tc.wantNewCommit) {
-				t.Errorf("new commit is:\n%s\n\nwant:\n%s", newCommit, tc.wantNewCommit)
-			}
-
-			if got, want := ok, tc.wantOK; got != want {
-				t.Errorf("ok = %t, want %t", got, want)
-			}
-		})
+		if loaded.sameSearch(&other) {
+			t.Errorf("[%d] sameSearch(%+v) = true, want false", n, other)
+		}
 	}
-}
 
-func TestSHA1State(t *testing.T) {
-	h1 := sha1.New()
-	h2 := sha1.New()
+	loaded.Nonce = ""
 
-	h1.Write([]byte("hello"))
+	if !loaded.sameSearch(state) {
+		t.Error("state without nonce is not for the header nonce")
+	}
 
-	d1, d2 := sha1State(h1), sha1State(h2)
-	*d2 = *d1
+	if err := os.WriteFile(path, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
 
-	if got, want := h2.Sum(nil), h1.Sum(nil); !bytes.Equal(got, want) {
-		t.Error("hashes differ")
+	if _, err := loadState(path); err == nil {
+		t.Error("got no error loading state without iterations")
 	}
 }
 
-func TestPaddedNSizeTailBlock(t *testing.T) {
-	for messageLen := range 3 * sha1.BlockSize {
-		head := []byte("foo")
-		message := bytes.Repeat([]byte("x"), messageLen)
-
-		h := sha1.New()
-
-		nBytes := []byte("12345")
-
-		h.Write(head)
-
-		objectSize := len(head) + len(nBytes) + len(message)
-
-		hashState := sha1State(h)
-
-		nOffset := hashState.nx
-
-		block := paddedNSizeTailBlock(hashState.x[:hashState.nx], len(nBytes), message, objectSize)
-
-		hashState.nx = 0
-
-		if len(block)%sha1.BlockSize != 0 {
-			t.Fatalf("[%d] padded size is %d, want a multiple of %d", messageLen, len(block), sha1.BlockSize)
-		}
-
-		copy(block[nOffset:], nBytes)
+func TestStatusLine(t *testing.T) {
+	var buf bytes.Buffer
 
-		h.Write(block)
+	status := &statusLine{w: &buf}
 
-		var gotSum [sha1.Size]byte
+	io.WriteString(status, "first\n")
+	status.set("status 1")
+	io.WriteString(status, "second\n")
+	status.set("status 2")
+	status.clear()
+	io.WriteString(status, "third\n")
 
-		for i, w := range sha1State(h).h {
-			binary.BigEndian.PutUint32(gotSum[i*4:], w)
-		}
+	want := "first\n" +
+		"\r\x1b[Kstatus 1" +
+		"\r\x1b[Ksecond\nstatus 1" +
+		"\r\x1b[Kstatus 2" +
+		"\r\x1b[K" +
+		"third\n"
 
-		wantSum := sha1.Sum(slices.Concat(head, nBytes, message))
-
-		if gotSum != wantSum {
-			t.Errorf("[%d] got %x, want %x", messageLen, gotSum, wantSum)
-		}
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
 	}
 }
 
-func TestAddToDigits(t *testing.T) {
-	for n, tc := range []struct {
-		digits string
-		step   int
-		want   string
-		wantOK bool
-	}{
-		{"0", 1, "1", true},
-		{"0", 9, "9", true},
-		{"0", 10, "0", false},
-		{"5", 5, "0", false},
-		{"99", 1, "00", false},
-		{"98", 1, "99", true},
-		{"1234", 8, "1242", true},
-		{"9995", 8, "0003", false},
-		{"999999999", 8, "000000007", false},
-		{"123456789", 8, "123456797", true},
-	} {
-		digits := []byte(tc.digits)
-
-		if got, want := addToDigits(digits, tc.step), tc.wantOK; got != want {
-			t.Errorf("[%d] addToDigits(%q, %d) = %t, want %t", n, tc.digits, tc.step, got, want)
-		}
-
-		if got, want := string(digits), tc.want; got != want {
-			t.Errorf("[%d] digits are %q, want %q", n, got, want)
-		}
+func TestJSONResult(t *testing.T) {
+	iteration := 39051708
+
+	result := jsonResult{
+		Event:           "result",
+		Commit:          "a27993c18f78a27993c18f78a27993c18f78a279",
+		Key:             "c0ffee",
+		Nonce:           vanity.NonceHeader,
+		Found:           true,
+		Hash:            "c0ffee83124285d152bd620725476c8a0eb9714e",
+		Iteration:       &iteration,
+		Matched:         vanity.AffixPattern("c0ffee", ""),
+		Tested:          39051709,
+		Rate:            80349673,
+		DurationSeconds: 0.486,
+		Written:         true,
+		Reset:           true,
 	}
-}
-
-func TestHashPrefixWords(t *testing.T) {
-	for n, tc := range []struct {
-		prefix    string
-		wantWords [5]uint32
-		wantMask  [5]uint32
-		wantN     int
-	}{
-		{"0", [5]uint32{}, [5]uint32{0xf0000000}, 1},
-		{"c0ffee", [5]uint32{0xc0ffee00}, [5]uint32{0xffffff00}, 1},
-		{"c0ffeebeef", [5]uint32{0xc0ffeebe, 0xef000000}, [5]uint32{0xffffffff, 0xff000000}, 2},
-	} {
-		words, mask, gotN := hashPrefixWords(tc.prefix)
 
-		if words != tc.wantWords {
-			t.Errorf("[%d] words = %08x, want %08x", n, words, tc.wantWords)
-		}
-
-		if mask != tc.wantMask {
-			t.Errorf("[%d] mask = %08x, want %08x", n, mask, tc.wantMask)
-		}
-
-		if gotN != tc.wantN {
-			t.Errorf("[%d] n = %d, want %d", n, gotN, tc.wantN)
-		}
+	b, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
 	}
-}
-
-func TestTrimHeader(t *testing.T) {
-	for n, tc := range []struct {
-		head   []byte
-		header string
-		want   []byte
-	}{
-		{
-			head:   []byte{},
-			header: "f00",
-			want:   []byte{},
-		},
-		{
-			head: []byte(`tree 0000000000000000000000000000000000000000
-author Author Name <author@example.com> 1577872800 +0000
-committer Committer Name <committer@example.com> 1577876400 +0100
-f00 123`),
-			header: "f00",
-			want: []byte(`tree 0000000000000000000000000000000000000000
-author Author Name <author@example.com> 1577872800 +0000
-committer Committer Name <committer@example.com> 1577876400 +0100`),
-		},
-		{
-			head: []byte(`tree 0000000000000000000000000000000000000000
-author Author Name <author@example.com> 1577872800 +0000
-committer Committer Name <committer@example.com> 1577876400 +0100
-f00 123`),
-			header: "unknown",
-			want: []byte(`tree 0000000000000000000000000000000000000000
-author Author Name <author@example.com> 1577872800 +0000
-committer Committer Name <committer@example.com> 1577876400 +0100
-f00 123`),
-		},
-		{
-			head: []byte(`tree 0000000000000000000000000000000000000000
-author Author Name <author@example.com> 1577872800 +0000
-f00 123
-committer Committer Name <committer@example.com> 1577876400 +0100`),
-			header: "f00",
-			want: []byte(`tree 0000000000000000000000000000000000000000
-author Author Name <author@example.com> 1577872800 +0000
-f00 123
-committer Committer Name <committer@example.com> 1577876400 +0100`),
-		},
-	} {
 
-		got := trimHeader(tc.head, tc.header)
+	want := `{"event":"result","commit":"a27993c18f78a27993c18f78a27993c18f78a279","key":"c0ffee","nonce":"header",` +
+		`"found":true,"hash":"c0ffee83124285d152bd620725476c8a0eb9714e","iteration":39051708,` +
+		`"matched":"c0ffee..................................","tested":39051709,"rate":80349673,` +
+		`"duration_seconds":0.486,"written":true,"reset":true}`
 
-		if !bytes.Equal(got, tc.want) {
-			t.Errorf("[%d] got:\n%s\n\nwant:\n%s", n, got, tc.want)
-		}
+	if got := string(b); got != want {
+		t.Errorf("got:\n%s\n\nwant:\n%s", got, want)
 	}
 }
 
@@ -367,8 +179,36 @@ func TestThousandSeparate(t *testing.T) {
 	}
 }
 
-func BenchmarkFind(b *testing.B) {
-	for b.Loop() {
-		find("c0ffee", "c0ffee", 0, []byte(commit))
+func TestCheckNice(t *testing.T) {
+	for _, tc := range []struct {
+		nice  int
+		valid bool
+	}{
+		{-1, false},
+		{0, true},
+		{1, true},
+		{19, true},
+		{20, false},
+	} {
+		if err := checkNice(tc.nice); (err == nil) != tc.valid {
+			t.Errorf("checkNice(%d) = %v, want valid %t", tc.nice, err, tc.valid)
+		}
+	}
+}
+
+func TestContinueHint(t *testing.T) {
+	for _, tc := range []struct {
+		statePath string
+		next, end int
+		want      string
+	}{
+		{"", 1234, 0, "To continue, use -start=1234"},
+		{"", 1234, 5000, "To continue, use -start=1234 -end=5000"},
+		{"search.state", 1234, 0, "To continue, rerun with the same -state search.state"},
+		{"search.state", 1234, 5000, "To continue, rerun with the same -state search.state"},
+	} {
+		if got := continueHint(tc.statePath, tc.next, tc.end); got != tc.want {
+			t.Errorf("continueHint(%q, %d, %d) = %q, want %q", tc.statePath, tc.next, tc.end, got, tc.want)
+		}
 	}
 }
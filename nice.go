@@ -0,0 +1,14 @@
+package main
+
+import "errors"
+
+// niceUsage is the usage of -nice, for the search and for workers alike.
+const niceUsage = "Scheduling niceness to run at, from 1 (slightly lower priority) to 19 (lowest), or 0 to leave it unchanged (Linux only)"
+
+// checkNice returns an error if nice is not a niceness accepted by -nice.
+func checkNice(nice int) error {
+	if nice < 0 || nice > 19 {
+		return errors.New("niceness must be between 0 (unchanged) and 19")
+	}
+	return nil
+}
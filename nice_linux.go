@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// setNice sets the scheduling niceness of the process. Linux keeps it per
+// thread, so it is set for every thread running so far, and threads started
+// later inherit it.
+func setNice(nice int) error {
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, nice); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// setNice sets the scheduling niceness of the process, which is only
+// supported on Linux.
+func setNice(nice int) error {
+	return errors.New("setting the niceness is only supported on Linux")
+}
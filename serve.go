@@ -0,0 +1,565 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jsageryd/git-vanity-commit/vanity"
+)
+
+const (
+	// leaseTimeout is how long a worker may go without being heard from before
+	// its range is leased to another.
+	leaseTimeout = 30 * time.Second
+
+	// shutdownGrace is how long a finished coordinator keeps telling workers
+	// that there is no more work before it stops listening.
+	shutdownGrace = 2 * heartbeatInterval
+)
+
+// coordinator hands out ranges of iterations to workers on other machines,
+// which search them and report back. Like a local search, it finds the lowest
+// matching iteration, by waiting for all ranges below the best one found.
+type coordinator struct {
+	opts        vanity.Options
+	patterns    []string
+	chunk       int
+	probability float64
+
+	// end is the iteration to stop before, as with Searcher.End
+	end int
+
+	// leaseTimeout is leaseTimeout, and shorter in tests
+	leaseTimeout time.Duration
+
+	listener net.Listener
+
+	mu sync.Mutex
+
+	// next is the first iteration not yet leased, and pending the ranges to
+	// lease again, lowest first
+	next    int
+	pending []span
+
+	leases map[int]*activeLease
+	lastID int
+
+	// tested is the number of commits tested in leases no longer active
+	tested int
+
+	best *vanity.Result
+
+	// over is set once no more work is to be done, and finished closed once
+	// the search is over by itself
+	over     bool
+	finished chan struct{}
+
+	workers map[string]*workerState
+}
+
+// span is a range of iterations, from start up to end.
+type span struct {
+	start, end int
+}
+
+// activeLease is a range leased to a worker.
+type activeLease struct {
+	span
+	worker string
+	tested int
+	seen   time.Time
+}
+
+// workerState is what the coordinator knows of a worker.
+type workerState struct {
+	seen time.Time
+
+	// told is whether the worker has been told that there is no more work
+	told bool
+}
+
+// The messages between workers and coordinator, as JSON over HTTP.
+type (
+	// leaseRequest asks for a range to search.
+	leaseRequest struct {
+		Worker string `json:"worker"`
+	}
+
+	// leaseResponse holds the range to search, or neither if there is none to
+	// search right now.
+	leaseResponse struct {
+		Done  bool   `json:"done,omitempty"`
+		Lease *lease `json:"lease,omitempty"`
+	}
+
+	// lease is a range to search, with all there is to know to search it.
+	lease struct {
+		ID      int      `json:"id"`
+		Commit  []byte   `json:"commit"`
+		Key     string   `json:"key"`
+		Nonce   string   `json:"nonce,omitempty"`
+		Type    string   `json:"type,omitempty"`
+		Line    string   `json:"line,omitempty"`
+		Format  string   `json:"format,omitempty"`
+		Targets []string `json:"targets"`
+		Start   int      `json:"start"`
+		End     int      `json:"end"`
+
+		// Encoding and Width are as in vanity.Options
+		Encoding string `json:"encoding,omitempty"`
+		Width    int    `json:"width,omitempty"`
+
+		// TimeWindow, TimeAuthor and TimeWhitespace are as in vanity.Options
+		TimeWindow     time.Duration `json:"time_window,omitempty"`
+		TimeAuthor     bool          `json:"time_author,omitempty"`
+		TimeWhitespace bool          `json:"time_whitespace,omitempty"`
+	}
+
+	// heartbeat tells the coordinator how far a worker has come with a lease.
+	heartbeat struct {
+		Worker string `json:"worker"`
+		Lease  int    `json:"lease"`
+		Tested int    `json:"tested"`
+	}
+
+	// heartbeatResponse tells the worker whether to stop searching its lease.
+	heartbeatResponse struct {
+		Stop bool `json:"stop"`
+	}
+
+	// report is the result of a searched lease.
+	report struct {
+		Worker    string `json:"worker"`
+		Lease     int    `json:"lease"`
+		Tested    int    `json:"tested"`
+		Found     bool   `json:"found"`
+		Iteration int    `json:"iteration"`
+	}
+)
+
+// newCoordinator returns a coordinator of a search for the targets of opts,
+// given as patterns, handing out ranges of chunk iterations to workers
+// connecting to the given address.
+func newCoordinator(opts vanity.Options, patterns []string, addr string, chunk int) (*coordinator, error) {
+	if opts.Scorer != nil {
+		return nil, errors.New("workers can only search for targets")
+	}
+
+	if chunk <= 0 {
+		return nil, errors.New("number of iterations to hand out must be positive")
+	}
+
+	s, err := vanity.NewSearcher(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	start := opts.Start
+	if len(opts.Resume) > 0 {
+		start = slices.Min(opts.Resume)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &coordinator{
+		opts:         opts,
+		patterns:     patterns,
+		chunk:        chunk,
+		probability:  s.Probability(),
+		end:          s.End(),
+		leaseTimeout: leaseTimeout,
+		listener:     listener,
+		next:         start,
+		leases:       make(map[int]*activeLease),
+		finished:     make(chan struct{}),
+		workers:      make(map[string]*workerState),
+	}, nil
+}
+
+// addr returns the address the coordinator listens on.
+func (c *coordinator) addr() net.Addr {
+	return c.listener.Addr()
+}
+
+// Search serves workers until the lowest matching iteration has been found, or
+// until ctx is done.
+func (c *coordinator) Search(ctx context.Context) (vanity.Result, error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /lease", c.handleLease)
+	mux.HandleFunc("POST /heartbeat", c.handleHeartbeat)
+	mux.HandleFunc("POST /report", c.handleReport)
+
+	srv := &http.Server{Handler: mux}
+
+	go srv.Serve(c.listener)
+
+	var err error
+
+	select {
+	case <-c.finished:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	c.mu.Lock()
+	c.over = true
+	c.mu.Unlock()
+
+	c.waitForWorkers()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	srv.Shutdown(shutdownCtx)
+
+	if err != nil {
+		return vanity.Result{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.best == nil {
+		return vanity.Result{}, vanity.ErrExhausted
+	}
+
+	return *c.best, nil
+}
+
+// waitForWorkers waits until all workers heard from recently have been told
+// that there is no more work, or for at most shutdownGrace.
+func (c *coordinator) waitForWorkers() {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	deadline := time.Now().Add(shutdownGrace)
+
+	for now := range ticker.C {
+		if now.After(deadline) {
+			return
+		}
+
+		c.mu.Lock()
+		waiting := false
+		for _, w := range c.workers {
+			if !w.told && now.Sub(w.seen) < c.leaseTimeout {
+				waiting = true
+			}
+		}
+		c.mu.Unlock()
+
+		if !waiting {
+			return
+		}
+	}
+}
+
+func (c *coordinator) handleLease(w http.ResponseWriter, r *http.Request) {
+	var req leaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ws := c.seen(req.Worker)
+
+	if c.over {
+		ws.told = true
+		writeJSON(w, leaseResponse{Done: true})
+		return
+	}
+
+	c.expire()
+
+	s, ok := c.nextSpan()
+	if !ok {
+		writeJSON(w, leaseResponse{})
+		return
+	}
+
+	c.lastID++
+	c.leases[c.lastID] = &activeLease{span: s, worker: req.Worker, seen: time.Now()}
+
+	writeJSON(w, leaseResponse{Lease: &lease{
+		ID:      c.lastID,
+		Commit:  c.opts.Commit,
+		Key:     c.opts.Key,
+		Nonce:   c.opts.Nonce,
+		Type:    c.opts.Type,
+		Line:    c.opts.Line,
+		Format:  c.opts.Format,
+		Targets: c.patterns,
+		Start:   s.start,
+		End:     s.end,
+
+		Encoding: c.opts.Encoding,
+		Width:    c.opts.Width,
+
+		TimeWindow:     c.opts.TimeWindow,
+		TimeAuthor:     c.opts.TimeAuthor,
+		TimeWhitespace: c.opts.TimeWhitespace,
+	}})
+}
+
+func (c *coordinator) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	var hb heartbeat
+	if err := json.NewDecoder(r.Body).Decode(&hb); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seen(hb.Worker)
+
+	// a worker told to stop asks for a new lease, and is told there is no
+	// more work then
+	l, ok := c.leases[hb.Lease]
+	if !ok || c.over {
+		writeJSON(w, heartbeatResponse{Stop: true})
+		return
+	}
+
+	l.seen = time.Now()
+	l.tested = hb.Tested
+
+	writeJSON(w, heartbeatResponse{})
+}
+
+func (c *coordinator) handleReport(w http.ResponseWriter, r *http.Request) {
+	var rep report
+	if err := json.NewDecoder(r.Body).Decode(&rep); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seen(rep.Worker)
+
+	l, ok := c.leases[rep.Lease]
+	if ok {
+		delete(c.leases, rep.Lease)
+		c.tested += rep.Tested
+	}
+
+	if rep.Found {
+		// a match outside the lease would leave the ranges below it unsearched
+		if !ok || rep.Iteration < l.start || rep.Iteration >= l.end {
+			log.Printf("Ignoring result outside of lease from worker %s (iteration %d)", rep.Worker, rep.Iteration)
+
+			if ok {
+				c.requeue(l.span)
+			}
+		} else if res, valid := c.verify(rep.Iteration); valid {
+			if c.best == nil || res.Iteration < c.best.Iteration {
+				c.best = &res
+				c.prune()
+			}
+		} else {
+			log.Printf("Ignoring invalid result from worker %s (iteration %d)", rep.Worker, rep.Iteration)
+
+			if ok {
+				c.requeue(l.span)
+			}
+		}
+	}
+
+	c.checkFinished()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// seen records that a worker has been heard from, and returns its state.
+func (c *coordinator) seen(worker string) *workerState {
+	ws, ok := c.workers[worker]
+	if !ok {
+		log.Printf("Worker %s connected", worker)
+		ws = &workerState{}
+		c.workers[worker] = ws
+	}
+	ws.seen = time.Now()
+	return ws
+}
+
+// expire leases the ranges of workers not heard from for too long again.
+func (c *coordinator) expire() {
+	for id, l := range c.leases {
+		if time.Since(l.seen) > c.leaseTimeout {
+			log.Printf("Worker %s timed out; leasing iterations %d to %d again", l.worker, l.start, l.end)
+			delete(c.leases, id)
+			c.tested += l.tested
+			c.requeue(l.span)
+		}
+	}
+}
+
+// nextSpan returns the next range to lease, if there is any left below the
+// best iteration found so far.
+func (c *coordinator) nextSpan() (span, bool) {
+	if len(c.pending) > 0 {
+		s := c.pending[0]
+		c.pending = c.pending[1:]
+		return s, true
+	}
+
+	end := c.limit()
+
+	if c.next >= end {
+		return span{}, false
+	}
+
+	s := span{start: c.next, end: end}
+	if c.next < end-c.chunk {
+		s.end = c.next + c.chunk
+	}
+
+	c.next = s.end
+
+	return s, true
+}
+
+// limit returns the iteration to search up to, which is the best iteration
+// found so far if there is one.
+func (c *coordinator) limit() int {
+	if c.best != nil {
+		return min(c.end, c.best.Iteration)
+	}
+	return c.end
+}
+
+// requeue queues a range to be leased again.
+func (c *coordinator) requeue(s span) {
+	c.pending = append(c.pending, s)
+	slices.SortFunc(c.pending, func(a, b span) int {
+		return a.start - b.start
+	})
+}
+
+// prune drops the leased and pending ranges from the best iteration found on.
+func (c *coordinator) prune() {
+	for id, l := range c.leases {
+		if l.start >= c.best.Iteration {
+			delete(c.leases, id)
+			c.tested += l.tested
+		}
+	}
+
+	c.pending = slices.DeleteFunc(c.pending, func(s span) bool {
+		return s.start >= c.best.Iteration
+	})
+
+	for i := range c.pending {
+		c.pending[i].end = min(c.pending[i].end, c.best.Iteration)
+	}
+}
+
+// checkFinished finishes the search if all ranges below the best iteration
+// found have been searched, or if there are no more ranges to search.
+func (c *coordinator) checkFinished() {
+	if c.over || len(c.pending) > 0 {
+		return
+	}
+
+	end := c.limit()
+
+	if c.next < end {
+		return
+	}
+
+	// leases that have expired are still waited for, until leased again
+	for _, l := range c.leases {
+		if l.start < end {
+			return
+		}
+	}
+
+	c.over = true
+	close(c.finished)
+}
+
+// verify searches the single given iteration, returning its result if it
+// matches.
+func (c *coordinator) verify(iteration int) (vanity.Result, bool) {
+	if iteration < 0 || iteration == math.MaxInt {
+		return vanity.Result{}, false
+	}
+
+	opts := c.opts
+	opts.Start, opts.End, opts.Resume, opts.Workers = iteration, iteration+1, nil, 1
+
+	s, err := vanity.NewSearcher(opts)
+	if err != nil {
+		return vanity.Result{}, false
+	}
+
+	res, err := s.Search(context.Background())
+
+	return res, err == nil
+}
+
+// Tested returns the number of commits tested so far by all workers.
+func (c *coordinator) Tested() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tested := c.tested
+	for _, l := range c.leases {
+		tested += l.tested
+	}
+	return tested
+}
+
+// Next returns the lowest iteration not yet searched.
+func (c *coordinator) Next() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	next := c.next
+	for _, l := range c.leases {
+		next = min(next, l.start)
+	}
+	for _, s := range c.pending {
+		next = min(next, s.start)
+	}
+	return next
+}
+
+// Frontier returns the lowest iteration not yet searched, from which a search
+// can be resumed.
+func (c *coordinator) Frontier() []int {
+	return []int{c.Next()}
+}
+
+// Probability returns the probability of a single commit matching any of the
+// targets.
+func (c *coordinator) Probability() float64 {
+	return c.probability
+}
+
+// End returns the iteration the search stops before.
+func (c *coordinator) End() int {
+	return c.end
+}
+
+// writeJSON writes v as the JSON body of a response.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
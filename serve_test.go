@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jsageryd/git-vanity-commit/vanity"
+)
+
+const testCommit = `tree 0000000000000000000000000000000000000000
+author Author Name <author@example.com> 1577872800 +0000
+committer Committer Name <committer@example.com> 1577876400 +0100
+
+Message
+`
+
+// testSearchOptions returns the options of a search for the given pattern in
+// testCommit.
+func testSearchOptions(t *testing.T, pattern string) vanity.Options {
+	target, err := vanity.ParseTarget(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return vanity.Options{Commit: []byte(testCommit), Key: "foo", Targets: []vanity.Target{target}}
+}
+
+// localSearch returns the result of searching locally with the given options.
+func localSearch(t *testing.T, opts vanity.Options) vanity.Result {
+	s, err := vanity.NewSearcher(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := s.Search(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+// startWorkers starts workers for the coordinator, returning a channel
+// receiving the error of each once it stops.
+func startWorkers(ctx context.Context, c *coordinator, n int) <-chan error {
+	errs := make(chan error, n)
+
+	for i := range n {
+		w := &worker{
+			coordinator: "http://" + c.addr().String(),
+			id:          fmt.Sprintf("worker-%d", i),
+			opts:        vanity.Options{Workers: 1},
+			client:      &http.Client{Timeout: 10 * time.Second},
+		}
+
+		go func() {
+			errs <- w.run(ctx)
+		}()
+	}
+
+	return errs
+}
+
+func TestCoordinator(t *testing.T) {
+	pattern := vanity.AffixPattern("c0ff", "")
+
+	opts := testSearchOptions(t, pattern)
+
+	want := localSearch(t, opts)
+
+	// small ranges, so that the match is found by one of many leases
+	c, err := newCoordinator(opts, []string{pattern}, "127.0.0.1:0", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	errs := startWorkers(ctx, c, 3)
+
+	res, err := c.Search(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Iteration != want.Iteration || res.Hash != want.Hash || string(res.Commit) != string(want.Commit) {
+		t.Errorf("got %s at iteration %d, want %s at iteration %d", res.Hash, res.Iteration, want.Hash, want.Iteration)
+	}
+
+	if got, want := c.Tested(), want.Iteration; got < want {
+		t.Errorf("tested = %d, want at least %d", got, want)
+	}
+
+	for range 3 {
+		if err := <-errs; err != nil {
+			t.Errorf("worker stopped with error %v, want none", err)
+		}
+	}
+}
+
+func TestCoordinatorExhausted(t *testing.T) {
+	pattern := vanity.AffixPattern("c0ffee", "")
+
+	opts := testSearchOptions(t, pattern)
+	opts.Start = 5000
+
+	c, err := newCoordinator(opts, []string{pattern}, "127.0.0.1:0", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the last range runs up to the highest iteration, so start right below
+	// it rather than search them all
+	c.next = math.MaxInt - 2500
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	startWorkers(ctx, c, 2)
+
+	if _, err := c.Search(ctx); !errors.Is(err, vanity.ErrExhausted) {
+		t.Fatalf("got error %v, want %v", err, vanity.ErrExhausted)
+	}
+}
+
+func TestCoordinatorEnd(t *testing.T) {
+	pattern := vanity.AffixPattern("c0ff", "")
+
+	opts := testSearchOptions(t, pattern)
+
+	want := localSearch(t, opts)
+
+	for _, tc := range []struct {
+		end     int
+		wantErr error
+	}{
+		{want.Iteration, vanity.ErrExhausted},
+		{want.Iteration + 1, nil},
+	} {
+		opts.End = tc.end
+
+		c, err := newCoordinator(opts, []string{pattern}, "127.0.0.1:0", 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		startWorkers(ctx, c, 2)
+
+		res, err := c.Search(ctx)
+		if !errors.Is(err, tc.wantErr) {
+			t.Fatalf("[end %d] got error %v, want %v", tc.end, err, tc.wantErr)
+		}
+
+		if err == nil && res.Iteration != want.Iteration {
+			t.Errorf("[end %d] iteration = %d, want %d", tc.end, res.Iteration, want.Iteration)
+		}
+	}
+}
+
+func TestCoordinatorTimestamp(t *testing.T) {
+	pattern := vanity.AffixPattern("c0f", "")
+
+	opts := testSearchOptions(t, pattern)
+	opts.Nonce, opts.TimeWindow, opts.TimeAuthor = vanity.NonceTimestamp, 30*time.Second, true
+
+	want := localSearch(t, opts)
+
+	c, err := newCoordinator(opts, []string{pattern}, "127.0.0.1:0", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	startWorkers(ctx, c, 2)
+
+	res, err := c.Search(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Hash != want.Hash || res.Iteration != want.Iteration || !bytes.Equal(res.Commit, want.Commit) {
+		t.Errorf("got %s at iteration %d, want %s at iteration %d", res.Hash, res.Iteration, want.Hash, want.Iteration)
+	}
+}
+
+func TestCoordinatorTimestampExhausted(t *testing.T) {
+	pattern := vanity.AffixPattern("c0ffee", "")
+
+	opts := testSearchOptions(t, pattern)
+	opts.Nonce, opts.TimeWindow = vanity.NonceTimestamp, time.Minute
+
+	c, err := newCoordinator(opts, []string{pattern}, "127.0.0.1:0", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	startWorkers(ctx, c, 2)
+
+	if _, err := c.Search(ctx); !errors.Is(err, vanity.ErrExhausted) {
+		t.Fatalf("got error %v, want %v", err, vanity.ErrExhausted)
+	}
+
+	if got, want := c.Tested(), 121; got != want {
+		t.Errorf("tested %d, want %d", got, want)
+	}
+}
+
+func TestCoordinatorLeaseTimeout(t *testing.T) {
+	pattern := vanity.AffixPattern("c0ff", "")
+
+	opts := testSearchOptions(t, pattern)
+
+	want := localSearch(t, opts)
+
+	// the first range holds the match, and is leased to a worker that never
+	// reports back
+	c, err := newCoordinator(opts, []string{pattern}, "127.0.0.1:0", want.Iteration+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.leaseTimeout = 100 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	results := make(chan vanity.Result)
+
+	go func() {
+		res, err := c.Search(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		results <- res
+	}()
+
+	lost := &worker{
+		coordinator: "http://" + c.addr().String(),
+		id:          "lost",
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+
+	var resp leaseResponse
+
+	if err := lost.post(ctx, "/lease", leaseRequest{Worker: lost.id}, &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Lease == nil || resp.Lease.Start != 0 {
+		t.Fatalf("lost worker got lease %+v, want the first range", resp.Lease)
+	}
+
+	startWorkers(ctx, c, 1)
+
+	if res := <-results; res.Iteration != want.Iteration {
+		t.Errorf("iteration = %d, want %d", res.Iteration, want.Iteration)
+	}
+}
+
+func TestCoordinatorInvalidResult(t *testing.T) {
+	pattern := vanity.AffixPattern("c0ff", "")
+
+	opts := testSearchOptions(t, pattern)
+
+	want := localSearch(t, opts)
+
+	c, err := newCoordinator(opts, []string{pattern}, "127.0.0.1:0", want.Iteration+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	results := make(chan vanity.Result)
+
+	go func() {
+		res, err := c.Search(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		results <- res
+	}()
+
+	liar := &worker{
+		coordinator: "http://" + c.addr().String(),
+		id:          "liar",
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+
+	var resp leaseResponse
+
+	if err := liar.post(ctx, "/lease", leaseRequest{Worker: liar.id}, &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	rep := report{Worker: liar.id, Lease: resp.Lease.ID, Found: true, Iteration: 1}
+
+	if err := liar.post(ctx, "/report", rep, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	startWorkers(ctx, c, 1)
+
+	if res := <-results; res.Iteration != want.Iteration {
+		t.Errorf("iteration = %d, want %d", res.Iteration, want.Iteration)
+	}
+}
+
+func TestCoordinatorReportOutOfSpan(t *testing.T) {
+	pattern := vanity.AffixPattern("c0ff", "")
+
+	opts := testSearchOptions(t, pattern)
+
+	want := localSearch(t, opts)
+
+	// a match above the lowest, which a worker reports for a lease it is not in
+	higherOpts := opts
+	higherOpts.Start = want.Iteration + 1
+	higher := localSearch(t, higherOpts)
+
+	c, err := newCoordinator(opts, []string{pattern}, "127.0.0.1:0", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	results := make(chan vanity.Result)
+
+	go func() {
+		res, err := c.Search(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		results <- res
+	}()
+
+	stale := &worker{
+		coordinator: "http://" + c.addr().String(),
+		id:          "stale",
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+
+	var first, second leaseResponse
+
+	if err := stale.post(ctx, "/lease", leaseRequest{Worker: stale.id}, &first); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stale.post(ctx, "/lease", leaseRequest{Worker: stale.id}, &second); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second lease is reported first, and the first with a match outside
+	// of it
+	for _, rep := range []report{
+		{Worker: stale.id, Lease: second.Lease.ID, Tested: 1000},
+		{Worker: stale.id, Lease: first.Lease.ID, Found: true, Iteration: higher.Iteration},
+	} {
+		if err := stale.post(ctx, "/report", rep, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c.mu.Lock()
+	best := c.best
+	c.mu.Unlock()
+
+	if best != nil {
+		t.Fatalf("accepted iteration %d from outside of lease %d to %d", best.Iteration, first.Lease.Start, first.Lease.End)
+	}
+
+	startWorkers(ctx, c, 2)
+
+	if res := <-results; res.Iteration != want.Iteration {
+		t.Errorf("iteration = %d, want %d", res.Iteration, want.Iteration)
+	}
+}
+
+func TestCoordinatorLeasesBelowBest(t *testing.T) {
+	pattern := vanity.AffixPattern("c0ff", "")
+
+	opts := testSearchOptions(t, pattern)
+
+	want := localSearch(t, opts)
+
+	c, err := newCoordinator(opts, []string{pattern}, "127.0.0.1:0", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.mu.Lock()
+
+	// a match found above iterations not yet leased
+	best := want
+	best.Iteration = want.Iteration + 5000
+	c.best = &best
+
+	s, ok := c.nextSpan()
+	if !ok || s.start != 0 {
+		t.Errorf("got span %+v (%t), want the first range", s, ok)
+	}
+
+	c.checkFinished()
+	if c.over {
+		t.Error("finished with iterations below the best match not searched")
+	}
+
+	c.mu.Unlock()
+}
@@ -0,0 +1,125 @@
+package vanity
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"slices"
+	"strconv"
+)
+
+// blockSize is the block size of both SHA-1 and SHA-256, on which objectBuffer
+// lays out objects.
+const blockSize = 64
+
+// either conversion fails to compile unless both block sizes are blockSize
+const (
+	_ = uint(blockSize-sha1.BlockSize) + uint(sha1.BlockSize-blockSize)
+	_ = uint(blockSize-sha256.BlockSize) + uint(sha256.BlockSize-blockSize)
+)
+
+// objectBuffer is the object holding the iteration number laid out for
+// hashing, with the bookkeeping shared by commitHasher and sha256Hasher. SHA-1
+// and SHA-256 have the same block size and padding, so the layout serves both.
+type objectBuffer struct {
+	layout *layout
+
+	// before and after are the contents of the object around the number in
+	// the current run
+	run           int
+	before, after []byte
+
+	nBytes []byte
+
+	// prefix is the object up to the number, of which all whole blocks come
+	// before buf
+	prefix []byte
+
+	// buf is the rest of the object from the end of those blocks, padded, with
+	// the number at nOffset
+	buf     []byte
+	nOffset int
+}
+
+// newObjectBuffer returns a buffer for the object with the iteration numbers
+// in the given layout.
+func newObjectBuffer(l *layout) objectBuffer {
+	return objectBuffer{layout: l, run: -1}
+}
+
+// advance moves the buffer to iteration n, given that it was at iteration
+// n-step. It reports whether the object was laid out anew, as when a run
+// starts or the number grows, and otherwise returns the offset in buf of the
+// first byte changed.
+func (b *objectBuffer) advance(n, step int) (changed int, laidOut bool) {
+	l := b.layout
+
+	// a new run starts over with new contents around the number
+	if run := l.run(n); run != b.run {
+		b.run = run
+		b.before, b.after = l.split(run)
+		b.nBytes = b.nBytes[:0]
+	}
+
+	first, ok := l.alphabet.add(b.nBytes, step)
+	if ok {
+		return b.nOffset + first, false
+	}
+
+	b.nBytes = l.append(b.nBytes[:0], l.value(n))
+	size := len(b.before) + len(b.nBytes) + len(b.after)
+
+	b.prefix = append(append(b.prefix[:0], l.objectType...), ' ')
+	b.prefix = strconv.AppendInt(b.prefix, int64(size), 10)
+	b.prefix = append(b.prefix, 0x00)
+	b.prefix = append(b.prefix, b.before...)
+
+	objectSize := len(b.prefix) + len(b.nBytes) + len(b.after)
+
+	b.nOffset = len(b.prefix) % blockSize
+
+	b.buf = paddedNSizeTailBlock(b.prefix[len(b.prefix)-b.nOffset:], len(b.nBytes), b.after, objectSize)
+	copy(b.buf[b.nOffset:], b.nBytes)
+	b.nBytes = b.buf[b.nOffset : b.nOffset+len(b.nBytes)]
+
+	return 0, true
+}
+
+// whole returns the whole blocks of the prefix, which are hashed before buf.
+func (b *objectBuffer) whole() []byte {
+	return b.prefix[:len(b.prefix)-b.nOffset]
+}
+
+// lastDigit returns the offset in buf of the last digit of the number.
+func (b *objectBuffer) lastDigit() int {
+	return b.nOffset + len(b.nBytes) - 1
+}
+
+// commit returns the object at the current iteration.
+func (b *objectBuffer) commit() []byte {
+	return slices.Concat(b.before, b.nBytes, b.after)
+}
+
+// commitAt returns the object at iteration n.
+func (b *objectBuffer) commitAt(n int) []byte {
+	return b.layout.commitAt(n)
+}
+
+// paddedNSizeTailBlock returns a buffer that starts with the given already
+// buffered bytes, leaves nLen bytes for the caller to fill in the nonce, and
+// ends with the given tail and the padding, on a block boundary.
+func paddedNSizeTailBlock(buffered []byte, nLen int, tail []byte, objectSize int) []byte {
+	size := len(buffered) + nLen + len(tail) + 1 + 8
+
+	if r := size % blockSize; r != 0 {
+		size += blockSize - r
+	}
+
+	block := make([]byte, size)
+	nOffset := copy(block, buffered)
+	copy(block[nOffset+nLen:], tail)
+	block[nOffset+nLen+len(tail)] = 0x80
+	binary.BigEndian.PutUint64(block[size-8:], uint64(objectSize)*8)
+
+	return block
+}
@@ -0,0 +1,134 @@
+package vanity
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The encodings of the number in the commit, for Options.Encoding.
+const (
+	EncodingDecimal = "decimal"
+	EncodingHex     = "hex"
+	EncodingBase36  = "base36"
+	EncodingBase62  = "base62"
+)
+
+// Encodings returns the encodings of the number in the commit, the default
+// first.
+func Encodings() []string {
+	return []string{EncodingDecimal, EncodingHex, EncodingBase36, EncodingBase62}
+}
+
+// defaultWidthBits is the binary logarithm of the number of iterations the
+// default width of an encoding other than decimal leaves room for, which is
+// far more than any search needs.
+const defaultWidthBits = 40
+
+// alphabet is the digits of the base iteration numbers are written in.
+type alphabet struct {
+	digits string
+
+	// values are the values of the digits, by digit
+	values [256]byte
+}
+
+var (
+	decimal     = newAlphabet("0123456789")
+	hexadecimal = newAlphabet("0123456789abcdef")
+	base36      = newAlphabet("0123456789abcdefghijklmnopqrstuvwxyz")
+	base62      = newAlphabet("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	whitespace  = newAlphabet(" \t")
+)
+
+// encodings are the alphabets of the encodings, by name.
+var encodings = map[string]*alphabet{
+	EncodingDecimal: decimal,
+	EncodingHex:     hexadecimal,
+	EncodingBase36:  base36,
+	EncodingBase62:  base62,
+}
+
+func newAlphabet(digits string) *alphabet {
+	a := &alphabet{digits: digits}
+	for i := range len(digits) {
+		a.values[digits[i]] = byte(i)
+	}
+	return a
+}
+
+// append appends n written in the alphabet to dst.
+func (a *alphabet) append(dst []byte, n int) []byte {
+	if a == decimal {
+		return strconv.AppendInt(dst, int64(n), 10)
+	}
+
+	start := len(dst)
+	base := len(a.digits)
+
+	for {
+		dst = append(dst, a.digits[n%base])
+		n /= base
+		if n == 0 {
+			break
+		}
+	}
+
+	for i, j := start, len(dst)-1; i < j; i, j = i+1, j-1 {
+		dst[i], dst[j] = dst[j], dst[i]
+	}
+
+	return dst
+}
+
+// defaultWidth returns the number of digits needed for 2^defaultWidthBits
+// numbers.
+func (a *alphabet) defaultWidth() int {
+	return int(math.Ceil(defaultWidthBits / math.Log2(float64(len(a.digits)))))
+}
+
+// written reports whether digits is a number written in the alphabet, padded
+// to the given width if not zero.
+func (a *alphabet) written(digits []byte, width int) bool {
+	if len(digits) == 0 || width > 0 && len(digits) != width {
+		return false
+	}
+	for _, d := range digits {
+		if strings.IndexByte(a.digits, d) == -1 {
+			return false
+		}
+	}
+	return true
+}
+
+// len returns the number of digits of n, for n at least zero.
+func (a *alphabet) len(n int) int {
+	base := len(a.digits)
+	l := 1
+	for n >= base {
+		n /= base
+		l++
+	}
+	return l
+}
+
+// add adds step to the digits in place. It returns the index of the first
+// digit changed, and reports whether the result still fits in the same number
+// of digits.
+func (a *alphabet) add(digits []byte, step int) (first int, ok bool) {
+	base := len(a.digits)
+	carry := step
+	first = len(digits)
+
+	for i := len(digits) - 1; i >= 0; i-- {
+		if carry == 0 {
+			return first, true
+		}
+		v := int(a.values[digits[i]]) + carry
+		digits[i] = a.digits[v%base]
+		carry = v / base
+		first = i
+	}
+
+	return first, carry == 0
+}
@@ -0,0 +1,89 @@
+package vanity
+
+import (
+	"testing"
+)
+
+func TestAlphabetAppend(t *testing.T) {
+	for n, tc := range []struct {
+		a    *alphabet
+		n    int
+		want string
+	}{
+		{decimal, 0, "0"},
+		{decimal, 1234, "1234"},
+		{whitespace, 0, " "},
+		{whitespace, 1, "\t"},
+		{whitespace, 2, "\t "},
+		{whitespace, 11, "\t \t\t"},
+		{hexadecimal, 255, "ff"},
+		{hexadecimal, 4096, "1000"},
+		{base36, 35, "z"},
+		{base36, 1295, "zz"},
+		{base62, 61, "Z"},
+		{base62, 62, "10"},
+	} {
+		got := string(tc.a.append([]byte("x"), tc.n))
+
+		if want := "x" + tc.want; got != want {
+			t.Errorf("[%d] got %q, want %q", n, got, want)
+		}
+
+		if got, want := tc.a.len(tc.n), len(tc.want); got != want {
+			t.Errorf("[%d] len(%d) = %d, want %d", n, tc.n, got, want)
+		}
+	}
+}
+
+func TestAlphabetAdd(t *testing.T) {
+	for n, tc := range []struct {
+		a         *alphabet
+		digits    string
+		step      int
+		want      string
+		wantFirst int
+		wantOK    bool
+	}{
+		{decimal, "0", 1, "1", 0, true},
+		{decimal, "0", 9, "9", 0, true},
+		{decimal, "0", 10, "0", 0, false},
+		{decimal, "5", 5, "0", 0, false},
+		{decimal, "99", 1, "00", 0, false},
+		{decimal, "98", 1, "99", 1, true},
+		{decimal, "1234", 0, "1234", 4, true},
+		{decimal, "1234", 8, "1242", 2, true},
+		{decimal, "9995", 8, "0003", 0, false},
+		{decimal, "999999999", 8, "000000007", 0, false},
+		{decimal, "123456789", 8, "123456797", 7, true},
+		{whitespace, " ", 1, "\t", 0, true},
+		{whitespace, "\t", 1, " ", 0, false},
+		{whitespace, "\t ", 1, "\t\t", 1, true},
+		{whitespace, "\t \t", 1, "\t\t ", 1, true},
+		{whitespace, "\t  ", 3, "\t\t\t", 1, true},
+		{whitespace, "\t\t\t", 3, " \t ", 0, false},
+		{hexadecimal, "0f", 1, "10", 0, true},
+		{hexadecimal, "ff", 1, "00", 0, false},
+		{hexadecimal, "00ff", 0x101, "0200", 1, true},
+		{base36, "0z", 1, "10", 0, true},
+		{base36, "az", 37, "c0", 0, true},
+		{base62, "0Z", 1, "10", 0, true},
+		{base62, "00Z", 62, "01Z", 1, true},
+		{base62, "ZZ", 1, "00", 0, false},
+	} {
+		digits := []byte(tc.digits)
+
+		first, ok := tc.a.add(digits, tc.step)
+
+		if ok != tc.wantOK {
+			t.Errorf("[%d] add(%q, %d) ok = %t, want %t", n, tc.digits, tc.step, ok, tc.wantOK)
+		}
+
+		if first != tc.wantFirst {
+			t.Errorf("[%d] add(%q, %d) first = %d, want %d", n, tc.digits, tc.step, first, tc.wantFirst)
+		}
+
+		if got, want := string(digits), tc.want; got != want {
+			t.Errorf("[%d] digits are %q, want %q", n, got, want)
+		}
+	}
+}
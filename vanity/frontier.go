@@ -0,0 +1,97 @@
+package vanity
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// frontier holds the next iteration to test for each worker. All earlier
+// iterations in the stride of a worker have been tested.
+type frontier struct {
+	next []atomic.Int64
+}
+
+// startFrontier returns the frontier of a search starting at startN using the
+// given number of workers.
+func startFrontier(startN, workers int) *frontier {
+	f := &frontier{next: make([]atomic.Int64, workers)}
+	for i := range f.next {
+		f.next[i].Store(int64(startN + i))
+	}
+	return f
+}
+
+// resumeFrontier returns the frontier of a search resumed from the given next
+// iterations. If the number of workers differs from that of the earlier
+// search, the search resumes from the lowest of them.
+func resumeFrontier(next []int, workers int) *frontier {
+	if len(next) != workers {
+		return startFrontier(slices.Min(next), workers)
+	}
+
+	f := &frontier{next: make([]atomic.Int64, workers)}
+	for i, n := range next {
+		f.next[i].Store(int64(n))
+	}
+	return f
+}
+
+// snapshot returns the next iteration to test for each worker.
+func (f *frontier) snapshot() []int {
+	next := make([]int, len(f.next))
+	for i := range f.next {
+		next[i] = int(f.next[i].Load())
+	}
+	return next
+}
+
+// min returns the lowest iteration not yet tested.
+func (f *frontier) min() int {
+	return slices.Min(f.snapshot())
+}
+
+// stopper stops interrupted workers at a common iteration, so that all
+// iterations below it are tested and none above it. Each worker registers the
+// next iteration it would test, and stops at the highest of them once all
+// workers have registered.
+type stopper struct {
+	stopN      atomic.Int64
+	pending    sync.WaitGroup
+	registered chan struct{}
+}
+
+func newStopper(workers int) *stopper {
+	s := &stopper{registered: make(chan struct{})}
+
+	s.pending.Add(workers)
+
+	go func() {
+		s.pending.Wait()
+		close(s.registered)
+	}()
+
+	return s
+}
+
+// register registers the next iteration of a worker, waits for all workers to
+// register, and returns the iteration to stop at.
+func (s *stopper) register(next int) (stopN int) {
+	for {
+		current := s.stopN.Load()
+		if int64(next) <= current || s.stopN.CompareAndSwap(current, int64(next)) {
+			break
+		}
+	}
+
+	s.pending.Done()
+
+	<-s.registered
+
+	return int(s.stopN.Load())
+}
+
+// leave registers a worker that has stopped for another reason.
+func (s *stopper) leave() {
+	s.pending.Done()
+}
@@ -0,0 +1,23 @@
+package vanity
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResumeFrontier(t *testing.T) {
+	for n, tc := range []struct {
+		next    []int
+		workers int
+		want    []int
+	}{
+		{[]int{0, 1, 2}, 3, []int{0, 1, 2}},
+		{[]int{300, 1201, 602}, 3, []int{300, 1201, 602}},
+		{[]int{300, 1201, 602}, 2, []int{300, 301}},
+		{[]int{300, 1201, 602}, 4, []int{300, 301, 302, 303}},
+	} {
+		if got, want := resumeFrontier(tc.next, tc.workers).snapshot(), tc.want; !slices.Equal(got, want) {
+			t.Errorf("[%d] resumeFrontier(%d, %d) = %d, want %d", n, tc.next, tc.workers, got, want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package vanity
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// RevParse returns the full object name of the given revision.
+func RevParse(rev string) (string, error) {
+	return gitRevParse("--verify", rev)
+}
+
+// RevParseShort returns the abbreviated object name of the given revision.
+func RevParseShort(rev string) (string, error) {
+	return gitRevParse("--short=12", "--verify", rev)
+}
+
+func gitRevParse(args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"rev-parse"}, args...)...).Output()
+	if err != nil {
+		return "", gitError("parsing revision", err)
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
+
+// The types of objects that can be searched, for Options.Type.
+const (
+	TypeCommit = "commit"
+	TypeTag    = "tag"
+	TypeBlob   = "blob"
+	TypeTree   = "tree"
+)
+
+// The object formats of repositories, for Options.Format.
+const (
+	FormatSHA1   = "sha1"
+	FormatSHA256 = "sha256"
+)
+
+// ObjectFormat returns the object format of the repository, as set by
+// extensions.objectFormat. Versions of git without --show-object-format print
+// it back rather than fail, and know of no format but SHA-1, so anything but a
+// known format falls back to the setting, or to FormatSHA1 without one.
+func ObjectFormat() string {
+	for _, args := range [][]string{
+		{"rev-parse", "--show-object-format"},
+		{"config", "extensions.objectFormat"},
+	} {
+		out, err := exec.Command("git", args...).Output()
+		if err != nil {
+			continue
+		}
+
+		switch format := strings.TrimSpace(string(out)); format {
+		case FormatSHA1, FormatSHA256:
+			return format
+		}
+	}
+
+	return FormatSHA1
+}
+
+// HashDigits returns the number of hex digits of a hash in the given object
+// format.
+func HashDigits(format string) int {
+	return hashSize(format) * 2
+}
+
+// hashSize returns the size in bytes of a hash in the given object format.
+func hashSize(format string) int {
+	if format == FormatSHA256 {
+		return sha256.Size
+	}
+	return sha1.Size
+}
+
+// FetchCommit returns the commit object at the given revision.
+func FetchCommit(rev string) ([]byte, error) {
+	typ, commit, err := FetchObject(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ != TypeCommit {
+		shortRev, err := RevParseShort(rev)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s is a %s object; expected a commit", shortRev, typ)
+	}
+
+	return commit, nil
+}
+
+// FetchObject returns the type and contents of the object at the given
+// revision: a commit, an annotated tag, a blob or a tree.
+func FetchObject(rev string) (typ string, object []byte, err error) {
+	shortRev, err := RevParseShort(rev)
+	if err != nil {
+		return "", nil, err
+	}
+
+	out, err := exec.Command("git", "cat-file", "-t", rev).Output()
+	if err != nil {
+		return "", nil, gitError("reading object type", err)
+	}
+
+	typ = strings.TrimSpace(string(out))
+	switch typ {
+	case TypeCommit, TypeTag, TypeBlob, TypeTree:
+	default:
+		return "", nil, fmt.Errorf("%s is a %s object; expected a commit, tag, blob or tree", shortRev, typ)
+	}
+
+	out, err = exec.Command("git", "cat-file", typ, rev).Output()
+	if err != nil {
+		return "", nil, gitError("reading "+typ, err)
+	}
+	return typ, out, nil
+}
+
+// WriteCommit writes the commit object to the repository, returning its hash.
+func WriteCommit(commit []byte) (hash string, err error) {
+	return WriteObject(TypeCommit, commit)
+}
+
+// WriteObject writes the object of the given type to the repository,
+// returning its hash.
+func WriteObject(typ string, object []byte) (hash string, err error) {
+	cmd := exec.Command("git", "hash-object", "--stdin", "-t", typ, "-w")
+	cmd.Stdin = bytes.NewReader(object)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", gitError("writing object", err)
+	}
+
+	return string(bytes.TrimSpace(out)), nil
+}
+
+// ResetTo resets the current branch to the given commit.
+func ResetTo(hash string) error {
+	if err := exec.Command("git", "reset", hash).Run(); err != nil {
+		return gitError("resetting to commit", err)
+	}
+	return nil
+}
+
+// TagRef returns the full name of the tag ref given as rev, as in
+// "refs/tags/v1.0.0", which the tag object pointed at may name differently.
+// Revisions that are not tag refs, such as object names, are refused.
+func TagRef(rev string) (string, error) {
+	ref, err := gitRevParse("--symbolic-full-name", rev)
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(ref, "refs/tags/") {
+		return "", fmt.Errorf("%s is not a tag under refs/tags/", rev)
+	}
+
+	return ref, nil
+}
+
+// UpdateTag points the tag ref of the given full name at the tag object with
+// the given hash, provided that it still points at old.
+func UpdateTag(ref, hash, old string) error {
+	if err := exec.Command("git", "update-ref", "-m", "git-vanity-commit", ref, hash, old).Run(); err != nil {
+		return gitError("updating tag", err)
+	}
+	return nil
+}
+
+// gitError returns an error for a failed git command, including what git
+// printed to stderr if anything.
+func gitError(doing string, err error) error {
+	if eErr, ok := err.(*exec.ExitError); ok && len(eErr.Stderr) > 0 {
+		return fmt.Errorf("error %s; git says %s", doing, bytes.TrimSpace(eErr.Stderr))
+	}
+	return fmt.Errorf("error %s: %w", doing, err)
+}
@@ -0,0 +1,118 @@
+package vanity
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestObjectFormat(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake git is a shell script")
+	}
+
+	for n, tc := range []struct {
+		revParse string
+		config   string
+		want     string
+	}{
+		{"echo sha256", "exit 1", FormatSHA256},
+		{"echo sha1", "echo sha256", FormatSHA1},
+
+		// git without --show-object-format prints it back
+		{"echo --show-object-format", "exit 1", FormatSHA1},
+		{"echo --show-object-format", "echo sha256", FormatSHA256},
+		{"echo --show-object-format", "echo unknown", FormatSHA1},
+		{"exit 128", "echo sha256", FormatSHA256},
+		{"exit 128", "exit 1", FormatSHA1},
+	} {
+		dir := t.TempDir()
+
+		script := "#!/bin/sh\ncase \"$1\" in\nrev-parse) " + tc.revParse + ";;\nconfig) " + tc.config + ";;\nesac\n"
+
+		if err := os.WriteFile(filepath.Join(dir, "git"), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Setenv("PATH", dir)
+
+		if got := ObjectFormat(); got != tc.want {
+			t.Errorf("[%d] ObjectFormat() = %q, want %q", n, got, tc.want)
+		}
+	}
+}
+
+func TestTagRef(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	t.Chdir(t.TempDir())
+
+	for _, env := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(env, "Name")
+	}
+	for _, env := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(env, "name@example.com")
+	}
+
+	git := func(args ...string) string {
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	git("init", "--quiet")
+	git("commit", "--quiet", "--allow-empty", "-m", "Message")
+	git("tag", "-a", "-m", "Release", "bar")
+
+	// foo points at a tag object naming itself bar
+	bar := git("rev-parse", "refs/tags/bar")
+	git("update-ref", "refs/tags/foo", bar)
+
+	for _, tc := range []struct {
+		rev     string
+		want    string
+		wantErr bool
+	}{
+		{"foo", "refs/tags/foo", false},
+		{"refs/tags/foo", "refs/tags/foo", false},
+		{"bar", "refs/tags/bar", false},
+		{bar, "", true},
+		{"HEAD", "", true},
+	} {
+		got, err := TagRef(tc.rev)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("TagRef(%q) error = %v, want error %t", tc.rev, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Errorf("TagRef(%q) = %q, want %q", tc.rev, got, tc.want)
+		}
+	}
+
+	// the tag given is moved, whatever the tag object names, and only if it
+	// has not moved since
+	git("tag", "-a", "-m", "Other", "baz")
+	baz := git("rev-parse", "refs/tags/baz")
+
+	if err := UpdateTag("refs/tags/foo", baz, baz); err == nil {
+		t.Error("UpdateTag moved a tag from where it was not")
+	}
+
+	if err := UpdateTag("refs/tags/foo", baz, bar); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := git("rev-parse", "refs/tags/foo"); got != baz {
+		t.Errorf("foo is at %s, want %s", got, baz)
+	}
+
+	if got := git("rev-parse", "refs/tags/bar"); got != bar {
+		t.Errorf("bar is at %s, want %s", got, bar)
+	}
+}
@@ -0,0 +1,300 @@
+package vanity
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+)
+
+// The places of the number in the commit, for Options.Nonce.
+const (
+	// NonceHeader places the number in a commit header with the key, as in
+	// "c0ffee 39051708", after all other headers.
+	NonceHeader = "header"
+
+	// NonceTrailer places the number in a trailer of the message, as in
+	// "Vanity-Nonce: 39051708", after any other trailers.
+	NonceTrailer = "trailer"
+
+	// NonceWhitespace places the number at the end of the last line of the
+	// message, in binary as spaces and tabs.
+	NonceWhitespace = "whitespace"
+
+	// NonceTimestamp adds no number, moving the committer timestamp within
+	// Options.TimeWindow instead.
+	NonceTimestamp = "timestamp"
+)
+
+// Nonces returns the places of the number in the commit, the default first.
+func Nonces() []string {
+	return []string{NonceHeader, NonceTrailer, NonceWhitespace, NonceTimestamp}
+}
+
+// trailerKey is the key of the trailer holding the number.
+const trailerKey = "Vanity-Nonce"
+
+var (
+	trailerLine      = regexp.MustCompile(`^[a-zA-Z0-9-]+[ \t]*:`).Match
+	trailerContinued = regexp.MustCompile(`^[ \t]`).Match
+	nonceTrailerLine = regexp.MustCompile(`(?i)^` + trailerKey + `[ \t]*:`).Match
+	signed           = regexp.MustCompile(`(?m)^-----BEGIN [A-Z ]+-----$`).Match
+)
+
+// splitCommit returns the contents of the commit before and after the number
+// for the given place of the number. Any number placed there by an earlier
+// search is removed.
+func splitCommit(commit []byte, nonce, key string) (before, after []byte, err error) {
+	head, tail, err := headTail(commit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch nonce {
+	case "", NonceHeader:
+		if !ValidKey(key) {
+			return nil, nil, fmt.Errorf("invalid key %q", key)
+		}
+
+		before = slices.Concat(trimHeader(head, key), []byte("\n"+key+" "))
+
+		return before, tail, nil
+	case NonceTrailer:
+		message := bytes.TrimRight(tail[len("\n\n"):], "\n")
+
+		if len(bytes.TrimSpace(message)) == 0 {
+			return nil, nil, errors.New("commit message must not be empty to hold the number in a trailer")
+		}
+
+		rest, trailers := splitTrailers(message)
+
+		before = slices.Concat(head, []byte("\n\n"), rest, []byte("\n\n"))
+		for _, line := range trailers {
+			before = append(append(before, line...), '\n')
+		}
+		before = append(before, trailerKey+": "...)
+
+		return before, []byte("\n"), nil
+	case NonceWhitespace:
+		message, final, err := splitMessage(tail)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return slices.Concat(head, []byte("\n\n"), message), final, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown nonce %q", nonce)
+	}
+}
+
+// splitMessage returns the message in tail without the spaces and tabs at the
+// end of its last line, and the final newline if there is one, for whitespace
+// to go in between.
+func splitMessage(tail []byte) (message, final []byte, err error) {
+	message = tail[len("\n\n"):]
+
+	if len(bytes.TrimSpace(message)) == 0 {
+		return nil, nil, errors.New("commit message must not be empty to hold the number in whitespace")
+	}
+
+	if bytes.HasSuffix(message, []byte("\n")) {
+		message, final = message[:len(message)-1], []byte("\n")
+	}
+
+	return bytes.TrimRight(message, " \t"), final, nil
+}
+
+// layout is where the iteration numbers go in a commit. The iterations are
+// split into runs of size iterations, or a single run if size is zero. Within
+// a run, the contents before and after the number are the same, and the number
+// written is base plus the position of the iteration in the run. It is padded
+// with zeros to width digits, if not zero.
+type layout struct {
+	objectType string
+	alphabet   *alphabet
+	width      int
+	base, size int
+
+	// end is the iteration to stop before, or zero if there is no end
+	end int
+
+	split func(run int) (before, after []byte)
+}
+
+// newLayout returns the layout of the iteration numbers in the commit of the
+// given options.
+func newLayout(opts Options) (*layout, error) {
+	l, err := nonceLayout(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	l.objectType = cmp.Or(opts.Type, TypeCommit)
+
+	return l, nil
+}
+
+// nonceLayout returns the layout for the place of the number in the options,
+// for an object of any type.
+func nonceLayout(opts Options) (*layout, error) {
+	switch opts.Type {
+	case "", TypeCommit:
+	case TypeTag:
+		// the signature of a tag is at the end of its message, covering
+		// everything before it
+		if signed(opts.Commit) {
+			return nil, errors.New("cannot change a signed tag without breaking its signature")
+		}
+
+		// git allows no extra headers in a tag
+		if opts.Nonce == "" || opts.Nonce == NonceHeader {
+			return nil, errors.New("the number cannot go in a header of a tag")
+		}
+	case TypeBlob, TypeTree:
+		// blobs and trees have a place of their own for the number
+		if opts.Nonce != "" {
+			return nil, fmt.Errorf("the number cannot go in a %s of a %s", opts.Nonce, opts.Type)
+		}
+	default:
+		return nil, fmt.Errorf("unknown object type %q", opts.Type)
+	}
+
+	a, ok := encodings[cmp.Or(opts.Encoding, EncodingDecimal)]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %q", opts.Encoding)
+	}
+
+	if opts.Width < 0 {
+		return nil, errors.New("nonce width must be positive")
+	}
+
+	switch opts.Nonce {
+	case NonceTimestamp:
+		if a != decimal || opts.Width > 0 {
+			return nil, errors.New("timestamps cannot be encoded differently")
+		}
+		return timeLayout(opts)
+	case NonceWhitespace:
+		if a != decimal {
+			return nil, errors.New("whitespace cannot be encoded differently")
+		}
+		a = whitespace
+	}
+
+	width := opts.Width
+	if width == 0 && a != decimal && a != whitespace {
+		width = a.defaultWidth()
+	}
+
+	var before, after []byte
+	var err error
+
+	switch opts.Type {
+	case TypeBlob:
+		before, after, err = splitBlob(opts.Commit, opts.Line, a, width)
+	case TypeTree:
+		before, after, err = splitTree(opts.Commit, opts.Format)
+	default:
+		before, after, err = splitCommit(opts.Commit, opts.Nonce, opts.Key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l := fixedLayout(before, after, a)
+
+	l.width = width
+
+	// a fixed width leaves no room for higher numbers
+	if l.width > 0 {
+		l.end = 1
+		for range l.width {
+			if l.end > math.MaxInt/len(a.digits) {
+				l.end = 0
+				break
+			}
+			l.end *= len(a.digits)
+		}
+	}
+
+	return l, nil
+}
+
+// fixedLayout returns the layout of a single run, with the number written in
+// the given alphabet between before and after.
+func fixedLayout(before, after []byte, a *alphabet) *layout {
+	return &layout{
+		alphabet: a,
+		split: func(int) ([]byte, []byte) {
+			return before, after
+		},
+	}
+}
+
+// run returns the run of iteration n.
+func (l *layout) run(n int) int {
+	if l.size == 0 {
+		return 0
+	}
+	return n / l.size
+}
+
+// value returns the number written for iteration n.
+func (l *layout) value(n int) int {
+	if l.size == 0 {
+		return l.base + n
+	}
+	return l.base + n%l.size
+}
+
+// append appends the number v to dst, padded to the width.
+func (l *layout) append(dst []byte, v int) []byte {
+	for range l.width - l.alphabet.len(v) {
+		dst = append(dst, l.alphabet.digits[0])
+	}
+	return l.alphabet.append(dst, v)
+}
+
+// len returns the number of digits v is written with, padded to the width.
+func (l *layout) len(v int) int {
+	return max(l.alphabet.len(v), l.width)
+}
+
+// commitAt returns the commit at iteration n.
+func (l *layout) commitAt(n int) []byte {
+	before, after := l.split(l.run(n))
+	return slices.Concat(before, l.append(nil, l.value(n)), after)
+}
+
+// splitTrailers splits the message, without its final newline, into the
+// paragraphs before its trailers and the trailer lines, leaving out any
+// trailer holding the number. As with git, the trailers are the last
+// paragraph, if it is not the first and all its lines are trailers or their
+// continuations.
+func splitTrailers(message []byte) (rest []byte, trailers [][]byte) {
+	start := bytes.LastIndex(message, []byte("\n\n"))
+	if start < 0 {
+		return message, nil
+	}
+
+	var skipping bool
+
+	for i, line := range bytes.Split(message[start+len("\n\n"):], []byte("\n")) {
+		switch {
+		case i > 0 && trailerContinued(line):
+		case trailerLine(line):
+			skipping = nonceTrailerLine(line)
+		default:
+			return message, nil
+		}
+
+		if !skipping {
+			trailers = append(trailers, line)
+		}
+	}
+
+	return bytes.TrimRight(message[:start], "\n"), trailers
+}
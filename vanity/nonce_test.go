@@ -0,0 +1,136 @@
+package vanity
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSplitCommit(t *testing.T) {
+	const head = "tree 0000000000000000000000000000000000000000\n" +
+		"author Author Name <author@example.com> 1577872800 +0000\n" +
+		"committer Committer Name <committer@example.com> 1577876400 +0100"
+
+	for n, tc := range []struct {
+		commit     string
+		nonce      string
+		wantBefore string
+		wantAfter  string
+	}{
+		{head + "\n\nMessage\n", "", head + "\nfoo ", "\n\nMessage\n"},
+		{head + "\n\nMessage\n", NonceHeader, head + "\nfoo ", "\n\nMessage\n"},
+		{head + "\nfoo 123\n\nMessage\n", NonceHeader, head + "\nfoo ", "\n\nMessage\n"},
+		{head + "\n\nMessage\n", NonceTrailer, head + "\n\nMessage\n\nVanity-Nonce: ", "\n"},
+		{head + "\n\nMessage", NonceTrailer, head + "\n\nMessage\n\nVanity-Nonce: ", "\n"},
+		{head + "\n\nMessage\n\nVanity-Nonce: 123\n", NonceTrailer, head + "\n\nMessage\n\nVanity-Nonce: ", "\n"},
+		{head + "\n\nMessage\n\nvanity-nonce : 123\n", NonceTrailer, head + "\n\nMessage\n\nVanity-Nonce: ", "\n"},
+		{head + "\n\nSubject\n\nBody\n", NonceTrailer, head + "\n\nSubject\n\nBody\n\nVanity-Nonce: ", "\n"},
+		{head + "\n\nSubject\n\nBody\n\n\n", NonceTrailer, head + "\n\nSubject\n\nBody\n\nVanity-Nonce: ", "\n"},
+		{head + "\n\nSubject\n\nBody: not a trailer\nas it goes on\n", NonceTrailer, head + "\n\nSubject\n\nBody: not a trailer\nas it goes on\n\nVanity-Nonce: ", "\n"},
+		{head + "\n\nSubject\n\nSigned-off-by: A <a@example.com>\n", NonceTrailer, head + "\n\nSubject\n\nSigned-off-by: A <a@example.com>\nVanity-Nonce: ", "\n"},
+		{head + "\n\nSubject\n\nSigned-off-by: A <a@example.com>\nVanity-Nonce: 123\n", NonceTrailer, head + "\n\nSubject\n\nSigned-off-by: A <a@example.com>\nVanity-Nonce: ", "\n"},
+		{head + "\n\nSubject\n\nVanity-Nonce: 123\nSigned-off-by: A <a@example.com>\n", NonceTrailer, head + "\n\nSubject\n\nSigned-off-by: A <a@example.com>\nVanity-Nonce: ", "\n"},
+		{head + "\n\nSubject\n\nFixes: 123\n  continued\nVanity-Nonce: 1\n  continued\n", NonceTrailer, head + "\n\nSubject\n\nFixes: 123\n  continued\nVanity-Nonce: ", "\n"},
+		{head + "\n\nVanity-Nonce: 123\n", NonceTrailer, head + "\n\nVanity-Nonce: 123\n\nVanity-Nonce: ", "\n"},
+		{head + "\n\nMessage\n", NonceWhitespace, head + "\n\nMessage", "\n"},
+		{head + "\n\nMessage", NonceWhitespace, head + "\n\nMessage", ""},
+		{head + "\n\nMessage\t \t\n", NonceWhitespace, head + "\n\nMessage", "\n"},
+		{head + "\n\nSubject\n\nBody \n", NonceWhitespace, head + "\n\nSubject\n\nBody", "\n"},
+		{head + "\nfoo 123\n\nMessage\n", NonceWhitespace, head + "\nfoo 123\n\nMessage", "\n"},
+	} {
+		before, after, err := splitCommit([]byte(tc.commit), tc.nonce, "foo")
+		if err != nil {
+			t.Fatalf("[%d] error: %v", n, err)
+		}
+
+		if got, want := string(before), tc.wantBefore; got != want {
+			t.Errorf("[%d] before is %q, want %q", n, got, want)
+		}
+
+		if got, want := string(after), tc.wantAfter; got != want {
+			t.Errorf("[%d] after is %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestSplitCommitErrors(t *testing.T) {
+	const head = "tree 0000000000000000000000000000000000000000"
+
+	for n, tc := range []struct {
+		commit string
+		nonce  string
+		key    string
+	}{
+		{head + "\n", NonceHeader, "foo"},
+		{head + "\n\nMessage\n", NonceHeader, "foo bar"},
+		{head + "\n\nMessage\n", "unknown", "foo"},
+		{head + "\n\n", NonceTrailer, "foo"},
+		{head + "\n\n", NonceWhitespace, "foo"},
+		{head + "\n\n \t\n", NonceWhitespace, "foo"},
+	} {
+		if _, _, err := splitCommit([]byte(tc.commit), tc.nonce, tc.key); err == nil {
+			t.Errorf("[%d] got no error", n)
+		}
+	}
+}
+
+func TestNewLayout(t *testing.T) {
+	for n, tc := range []struct {
+		nonce, encoding string
+		width           int
+		iteration       int
+		wantNumber      string
+		wantEnd         int64
+	}{
+		{NonceHeader, "", 0, 1234, "1234", 0},
+		{NonceHeader, EncodingDecimal, 6, 1234, "001234", 1_000_000},
+		{NonceHeader, EncodingHex, 0, 1234, "00000004d2", 1 << 40},
+		{NonceHeader, EncodingHex, 4, 1234, "04d2", 1 << 16},
+		{NonceHeader, EncodingBase36, 0, 1234, "000000ya", 36 * 36 * 36 * 36 * 36 * 36 * 36 * 36},
+		{NonceTrailer, EncodingBase62, 0, 1234, "00000jU", 62 * 62 * 62 * 62 * 62 * 62 * 62},
+		{NonceTrailer, EncodingBase62, 2, 1234, "jU", 62 * 62},
+		{NonceWhitespace, "", 0, 5, "\t \t", 0},
+		{NonceWhitespace, "", 4, 5, " \t \t", 16},
+	} {
+		l, err := newLayout(Options{Commit: []byte(commit), Key: "foo", Nonce: tc.nonce, Encoding: tc.encoding, Width: tc.width})
+		if err != nil {
+			t.Fatalf("[%d] error: %v", n, err)
+		}
+
+		before, after := l.split(0)
+
+		if got, want := string(l.commitAt(tc.iteration)), string(before)+tc.wantNumber+string(after); got != want {
+			t.Errorf("[%d] commit is %q, want %q", n, got, want)
+		}
+
+		// there is no end where ints are too small to reach it
+		wantEnd := tc.wantEnd
+		if wantEnd > math.MaxInt {
+			wantEnd = 0
+		}
+
+		if got, want := int64(l.end), wantEnd; got != want {
+			t.Errorf("[%d] end = %d, want %d", n, got, want)
+		}
+	}
+}
+
+func TestNewLayoutErrors(t *testing.T) {
+	for n, opts := range []Options{
+		{Commit: []byte(commit), Key: "foo", Encoding: "unknown"},
+		{Commit: []byte(commit), Key: "foo", Width: -1},
+		{Commit: []byte(commit), Nonce: NonceWhitespace, Encoding: EncodingHex},
+		{Commit: []byte(commit), Nonce: NonceTimestamp, TimeWindow: time.Hour, Encoding: EncodingHex},
+		{Commit: []byte(commit), Nonce: NonceTimestamp, TimeWindow: time.Hour, Width: 12},
+		{Commit: []byte(commit), Key: "foo", Type: "unknown"},
+		{Commit: []byte(commit), Key: "foo", Type: TypeBlob, Nonce: NonceHeader},
+		{Commit: []byte(tag), Type: TypeTag, Key: "foo"},
+		{Commit: []byte(tag), Type: TypeTag, Key: "foo", Nonce: NonceHeader},
+		{Commit: []byte(tag), Type: TypeTag, Nonce: NonceTimestamp, TimeWindow: time.Hour, TimeAuthor: true},
+		{Commit: []byte(tag + "-----BEGIN PGP SIGNATURE-----\n\niQ\n-----END PGP SIGNATURE-----\n"), Type: TypeTag, Nonce: NonceTrailer},
+	} {
+		if _, err := newLayout(opts); err == nil {
+			t.Errorf("[%d] got no error", n)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package vanity
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultLine is the template of the line holding the number at the end of a
+// blob, for Options.Line.
+const DefaultLine = trailerKey + ": {n}"
+
+// linePlaceholder is where the number goes in the template of a line.
+const linePlaceholder = "{n}"
+
+// entryPrefix is the start of the name of the tree entry holding the number,
+// which is followed by the number.
+const entryPrefix = ".vanity-"
+
+// nonceDigits matches the digits of a number placed in the name of a tree
+// entry by an earlier search, in any encoding.
+var nonceDigits = regexp.MustCompile(`^[0-9a-zA-Z]+$`).Match
+
+// splitBlob returns the contents of the blob before and after the number, in
+// a line at its end made from the given template. A line placed there by an
+// earlier search, with a number written in the given alphabet and width, is
+// removed, while any other last line is kept.
+func splitBlob(blob []byte, template string, a *alphabet, width int) (before, after []byte, err error) {
+	template = cmp.Or(template, DefaultLine)
+
+	prefix, suffix, ok := strings.Cut(template, linePlaceholder)
+	if !ok || strings.Contains(suffix, linePlaceholder) {
+		return nil, nil, fmt.Errorf("line template %q must have %s once", template, linePlaceholder)
+	}
+
+	if prefix == "" && suffix == "" {
+		return nil, nil, fmt.Errorf("line template %q must have more than %s", template, linePlaceholder)
+	}
+
+	if strings.Contains(template, "\n") {
+		return nil, nil, fmt.Errorf("line template %q must be a single line", template)
+	}
+
+	contents := blob
+	if lines, ok := bytes.CutSuffix(blob, []byte("\n")); ok {
+		start := bytes.LastIndexByte(lines, '\n') + 1
+		if digits, ok := cutAround(lines[start:], prefix, suffix); ok && a.written(digits, width) {
+			contents = blob[:start]
+		}
+	}
+
+	before = slices.Clone(contents)
+	if len(before) > 0 && !bytes.HasSuffix(before, []byte("\n")) {
+		before = append(before, '\n')
+	}
+
+	return append(before, prefix...), []byte(suffix + "\n"), nil
+}
+
+// cutAround returns line without the given prefix and suffix, and whether it
+// has both.
+func cutAround(line []byte, prefix, suffix string) ([]byte, bool) {
+	if len(line) < len(prefix)+len(suffix) {
+		return nil, false
+	}
+	if !bytes.HasPrefix(line, []byte(prefix)) || !bytes.HasSuffix(line, []byte(suffix)) {
+		return nil, false
+	}
+	return line[len(prefix) : len(line)-len(suffix)], true
+}
+
+// emptyBlob returns the hash of the empty blob in the given object format,
+// which the tree entry holding the number points to.
+func emptyBlob(format string) []byte {
+	if format == FormatSHA256 {
+		sum := sha256.Sum256([]byte("blob 0\x00"))
+		return sum[:]
+	}
+	sum := sha1.Sum([]byte("blob 0\x00"))
+	return sum[:]
+}
+
+// splitTree returns the contents of the tree before and after the number, in
+// the name of an extra entry for the empty blob, sorted among the others as
+// git requires. An entry placed there by an earlier search is removed. The
+// hashes in the tree are of the given object format.
+func splitTree(tree []byte, format string) (before, after []byte, err error) {
+	size, empty := hashSize(format), emptyBlob(format)
+
+	var entries [][]byte
+	insert := 0
+
+	for rest := tree; len(rest) > 0; {
+		mode, name, ok := bytes.Cut(rest, []byte(" "))
+		if !ok {
+			return nil, nil, errors.New("cannot parse tree")
+		}
+
+		name, id, ok := bytes.Cut(name, []byte{0x00})
+		if !ok || len(id) < size {
+			return nil, nil, errors.New("cannot parse tree")
+		}
+
+		entry := rest[:len(rest)-len(id)+size]
+		rest = id[size:]
+
+		if digits, ok := bytes.CutPrefix(name, []byte(entryPrefix)); ok {
+			if string(mode) == "100644" && nonceDigits(digits) && bytes.Equal(id[:size], empty) {
+				continue
+			}
+			return nil, nil, fmt.Errorf("tree has an entry %q in the way of the number", name)
+		}
+
+		// git sorts trees as if their names ended with a slash, and as no
+		// other name starts with the prefix, comparing with the prefix alone
+		// places the entry whatever the number
+		key := name
+		if string(mode) == "40000" {
+			key = slices.Concat(name, []byte("/"))
+		}
+
+		if bytes.Compare(key, []byte(entryPrefix)) < 0 {
+			insert = len(entries) + 1
+		}
+
+		entries = append(entries, entry)
+	}
+
+	before = slices.Concat(slices.Concat(entries[:insert]...), []byte("100644 "+entryPrefix))
+	after = slices.Concat([]byte{0x00}, empty, slices.Concat(entries[insert:]...))
+
+	return before, after, nil
+}
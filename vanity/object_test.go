@@ -0,0 +1,181 @@
+package vanity
+
+import (
+	"cmp"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSplitBlob(t *testing.T) {
+	for n, tc := range []struct {
+		blob       string
+		template   string
+		encoding   string
+		width      int
+		wantBefore string
+		wantAfter  string
+	}{
+		{"", "", "", 0, "Vanity-Nonce: ", "\n"},
+		{"contents\n", "", "", 0, "contents\nVanity-Nonce: ", "\n"},
+		{"contents", "", "", 0, "contents\nVanity-Nonce: ", "\n"},
+		{"contents\nVanity-Nonce: 123\n", "", "", 0, "contents\nVanity-Nonce: ", "\n"},
+		{"contents\nVanity-Nonce: 00Ab\n", "", EncodingBase62, 4, "contents\nVanity-Nonce: ", "\n"},
+		{"contents\nVanity-Nonce: 00Ab\n", "", "", 0, "contents\nVanity-Nonce: 00Ab\nVanity-Nonce: ", "\n"},
+		{"contents\nVanity-Nonce: 00ab\n", "", EncodingHex, 8, "contents\nVanity-Nonce: 00ab\nVanity-Nonce: ", "\n"},
+		{"contents\nVanity-Nonce: 123", "", "", 0, "contents\nVanity-Nonce: 123\nVanity-Nonce: ", "\n"},
+		{"contents\nVanity-Nonce: not a number\n", "", "", 0, "contents\nVanity-Nonce: not a number\nVanity-Nonce: ", "\n"},
+		{"Vanity-Nonce: 123\n", "", "", 0, "Vanity-Nonce: ", "\n"},
+		{"contents\n", "/* {n} */", "", 0, "contents\n/* ", " */\n"},
+		{"contents\n/* 123 */\n", "/* {n} */", "", 0, "contents\n/* ", " */\n"},
+		{"contents\n/**/\n", "/* {n} */", "", 0, "contents\n/**/\n/* ", " */\n"},
+		{"contents\n", "{n}#", "", 0, "contents\n", "#\n"},
+
+		// an unrelated last line that happens to fit the template is kept
+		{"contents\n# TODO\n", "# {n}", "", 0, "contents\n# TODO\n# ", "\n"},
+		{"contents\n# 42\n", "# {n}", "", 3, "contents\n# 42\n# ", "\n"},
+		{"contents\n# 042\n", "# {n}", "", 3, "contents\n# ", "\n"},
+	} {
+		a := encodings[cmp.Or(tc.encoding, EncodingDecimal)]
+
+		before, after, err := splitBlob([]byte(tc.blob), tc.template, a, tc.width)
+		if err != nil {
+			t.Fatalf("[%d] error: %v", n, err)
+		}
+
+		if got, want := string(before), tc.wantBefore; got != want {
+			t.Errorf("[%d] before is %q, want %q", n, got, want)
+		}
+
+		if got, want := string(after), tc.wantAfter; got != want {
+			t.Errorf("[%d] after is %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestSplitBlobErrors(t *testing.T) {
+	for n, template := range []string{
+		"no placeholder",
+		"{n}",
+		"{n} {n}",
+		"two\nlines {n}",
+	} {
+		if _, _, err := splitBlob([]byte("contents\n"), template, decimal, 0); err == nil {
+			t.Errorf("[%d] got no error", n)
+		}
+	}
+}
+
+// treeEntry returns a tree entry for the object with the given hash in hex.
+func treeEntry(mode, name, hash string) string {
+	id, err := hex.DecodeString(hash)
+	if err != nil {
+		panic(err)
+	}
+	return mode + " " + name + "\x00" + string(id)
+}
+
+func TestSplitTree(t *testing.T) {
+	const blob = "0123456789abcdef0123456789abcdef01234567"
+
+	nonce := func(n string) string {
+		return treeEntry("100644", ".vanity-"+n, fmt.Sprintf("%x", emptyBlob(FormatSHA1)))
+	}
+
+	var (
+		a         = treeEntry("100644", "a", blob)
+		dot       = treeEntry("100644", ".a", blob)
+		vanity    = treeEntry("100644", ".vanity", blob)
+		vanityDir = treeEntry("40000", ".vanity", blob)
+		after     = nonce("")[len("100644 .vanity-"):]
+	)
+
+	for n, tc := range []struct {
+		tree       string
+		wantBefore string
+		wantAfter  string
+	}{
+		{"", "100644 .vanity-", after},
+		{a, "100644 .vanity-", after + a},
+		{dot + a, dot + "100644 .vanity-", after + a},
+		{dot + nonce("123") + a, dot + "100644 .vanity-", after + a},
+		{dot + nonce("00Ab") + a, dot + "100644 .vanity-", after + a},
+		{vanity + a, vanity + "100644 .vanity-", after + a},
+		{vanityDir + a, "100644 .vanity-", after + vanityDir + a},
+	} {
+		before, after, err := splitTree([]byte(tc.tree), FormatSHA1)
+		if err != nil {
+			t.Fatalf("[%d] error: %v", n, err)
+		}
+
+		if got, want := string(before), tc.wantBefore; got != want {
+			t.Errorf("[%d] before is %q, want %q", n, got, want)
+		}
+
+		if got, want := string(after), tc.wantAfter; got != want {
+			t.Errorf("[%d] after is %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestSplitTreeErrors(t *testing.T) {
+	const blob = "0123456789abcdef0123456789abcdef01234567"
+
+	for n, tree := range []string{
+		"100644 a",
+		"100644 a\x00short",
+		treeEntry("100644", ".vanity-readme.txt", blob),
+		treeEntry("100644", ".vanity-123", blob),
+		treeEntry("40000", ".vanity-123", fmt.Sprintf("%x", emptyBlob(FormatSHA1))),
+	} {
+		if _, _, err := splitTree([]byte(tree), FormatSHA1); err == nil {
+			t.Errorf("[%d] got no error", n)
+		}
+	}
+}
+
+func TestSearchBlobAndTree(t *testing.T) {
+	for _, tc := range []struct {
+		typ    string
+		object string
+		want   func(iteration int) string
+	}{
+		{TypeBlob, "contents\n", func(n int) string {
+			return fmt.Sprintf("contents\nVanity-Nonce: %d\n", n)
+		}},
+		{TypeTree, treeEntry("100644", "a", "0123456789abcdef0123456789abcdef01234567"), func(n int) string {
+			return treeEntry("100644", fmt.Sprintf(".vanity-%d", n), fmt.Sprintf("%x", emptyBlob(FormatSHA1))) +
+				treeEntry("100644", "a", "0123456789abcdef0123456789abcdef01234567")
+		}},
+	} {
+		t.Run(tc.typ, func(t *testing.T) {
+			s, err := NewSearcher(Options{
+				Commit:  []byte(tc.object),
+				Type:    tc.typ,
+				Targets: []Target{newTarget(AffixPattern("c0f", ""))},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := s.Search(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := res.Hash, objectHash(tc.typ, res.Commit); got != want {
+				t.Errorf("hash = %q, want %q", got, want)
+			}
+
+			if !strings.HasPrefix(res.Hash, "c0f") {
+				t.Errorf("hash %q does not start with c0f", res.Hash)
+			}
+
+			if got, want := string(res.Commit), tc.want(res.Iteration); got != want {
+				t.Errorf("new %s is:\n%q\n\nwant:\n%q", tc.typ, got, want)
+			}
+		})
+	}
+}
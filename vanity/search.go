@@ -0,0 +1,648 @@
+// Package vanity finds vanity hashes for git commits, annotated tags, blobs
+// and trees. It does this by adding a number to the object, incremented until
+// its hash matches. In a commit, the number goes in a header by default, or in
+// a trailer or whitespace at the end of the message, or the committer
+// timestamp is moved instead. A tag takes the same places but a header, with
+// the tagger timestamp moved rather than the committer one. A blob gets a last
+// line holding the number, and a tree an extra entry named after it.
+package vanity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrExhausted is returned by Search when all iterations have been tested
+// without finding a hash.
+var ErrExhausted = errors.New("no hash found")
+
+// Options configure a Searcher.
+type Options struct {
+	// Commit is the commit to start from, as printed by git cat-file commit,
+	// or the object of the given Type.
+	Commit []byte
+
+	// Type is the type of the object in Commit, TypeCommit, TypeTag, TypeBlob
+	// or TypeTree, or empty for TypeCommit. The timestamp of a tag is that of
+	// the tagger, and as git allows no extra headers in a tag, Nonce must not
+	// be NonceHeader or empty for one. Blobs and trees have a place of their
+	// own for the number, so Nonce must be empty for them: a line at the end
+	// of a blob, made from Line, or the name of an extra entry of a tree for
+	// the empty blob, as in ".vanity-39051708".
+	Type string
+
+	// Line is the template of the line holding the number at the end of a
+	// blob, with "{n}" where the number goes, or empty for DefaultLine.
+	Line string
+
+	// Format is the object format of the repository, FormatSHA1 or
+	// FormatSHA256, or empty for FormatSHA1. The targets must be for hashes
+	// of its size. SHA-256 is hashed one iteration at a time in full, with
+	// the scalar backend only, so unlike SHA-1 every candidate costs a whole
+	// hash even when a single word of it rules it out.
+	Format string
+
+	// Key is the key of the commit header holding the iteration number, when
+	// the number goes in a header.
+	Key string
+
+	// Nonce is where in the commit the iteration number goes, one of those
+	// returned by Nonces, or empty for NonceHeader.
+	Nonce string
+
+	// Encoding is how the iteration number is written in a header or trailer,
+	// one of those returned by Encodings, or empty for EncodingDecimal.
+	Encoding string
+
+	// Width, if positive, is the number of digits the iteration number is
+	// written with, padded with zeros, so that the commit keeps its size and
+	// the search ends once all numbers of that width have been tested. Zero
+	// means a growing number with EncodingDecimal and NonceWhitespace, and
+	// room for far more iterations than any search needs with other
+	// encodings.
+	Width int
+
+	// TimeWindow is how far either side of its value the committer timestamp
+	// may be moved with NonceTimestamp, in whole seconds. The earliest
+	// matching timestamp is used.
+	TimeWindow time.Duration
+
+	// TimeAuthor, with NonceTimestamp, also moves the author timestamp within
+	// the window, once for each pass of the committer timestamp through it.
+	TimeAuthor bool
+
+	// TimeWhitespace, with NonceTimestamp, goes on with whitespace at the end
+	// of the commit message as with NonceWhitespace once the window has been
+	// searched, instead of ending the search there.
+	TimeWhitespace bool
+
+	// Targets are the hashes to find. The search stops at the lowest
+	// iteration matching any of them.
+	Targets []Target
+
+	// Scorer, if set instead of Targets, makes the search run until its
+	// context is done, keeping the best scoring hash found.
+	Scorer *Scorer
+
+	// Start is the iteration to start from.
+	Start int
+
+	// End, if positive, is the iteration to stop before. A search for targets
+	// that reaches it returns ErrExhausted.
+	End int
+
+	// Resume, if set, overrides Start with the frontier of an earlier search
+	// as returned by Searcher.Frontier.
+	Resume []int
+
+	// Workers is the number of concurrent workers, or zero for one per CPU.
+	Workers int
+
+	// CPUPercent, if between 1 and 99, limits each worker to that percentage
+	// of the time of a CPU by having it sleep in between. Zero means no
+	// limit.
+	CPUPercent int
+
+	// Backend is the name of the hashing backend to use, one of those
+	// returned by Backends, or empty for the default.
+	Backend string
+
+	// Improved, if set, is called with each improvement on the best hash
+	// found so far when searching with a Scorer.
+	Improved func(Result)
+}
+
+// Result is a hash found by a search.
+type Result struct {
+	// Hash is the hash of the new commit in hex.
+	Hash string
+
+	// Iteration is the iteration of the new commit, which is the number in
+	// it as written in the encoding, except with NonceTimestamp.
+	Iteration int
+
+	// Commit is the new commit.
+	Commit []byte
+
+	// Matched is the index of the target matched, when searching for targets.
+	Matched int
+
+	// Score is the score of the hash, when searching with a scorer.
+	Score int
+}
+
+// Searcher searches for vanity hashes.
+type Searcher struct {
+	opts Options
+
+	layout *layout
+
+	f *frontier
+	m *matcher
+
+	backend *sha1Backend
+
+	tested atomic.Int64
+}
+
+// NewSearcher returns a searcher with the given options.
+func NewSearcher(opts Options) (*Searcher, error) {
+	if len(opts.Targets) == 0 && opts.Scorer == nil {
+		return nil, errors.New("missing targets or scorer")
+	}
+
+	if len(opts.Targets) > 0 && opts.Scorer != nil {
+		return nil, errors.New("targets cannot be combined with a scorer")
+	}
+
+	if opts.Format != "" && opts.Format != FormatSHA1 && opts.Format != FormatSHA256 {
+		return nil, fmt.Errorf("unknown object format %q", opts.Format)
+	}
+
+	for _, t := range opts.Targets {
+		if t.Bits() == 0 {
+			return nil, errors.New("target must have at least one fixed digit")
+		}
+
+		if digits := HashDigits(opts.Format); t.digits != digits {
+			return nil, fmt.Errorf("target is for hashes of %d digits, not %d", t.digits, digits)
+		}
+	}
+
+	if opts.Start < 0 {
+		return nil, errors.New("starting iteration must be positive")
+	}
+
+	if opts.End < 0 {
+		return nil, errors.New("ending iteration must be positive")
+	}
+
+	layout, err := newLayout(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := opts.Workers
+	if workers == 0 {
+		workers = numWorkers()
+	}
+
+	if workers < 0 {
+		return nil, errors.New("number of workers must be positive")
+	}
+
+	if opts.CPUPercent < 0 || opts.CPUPercent > 100 {
+		return nil, errors.New("CPU percentage must be between 0 and 100")
+	}
+
+	backend, err := lookupBackend(opts.Backend)
+	if err != nil {
+		return nil, err
+	}
+
+	// the backends hash SHA-1 only, and SHA-256 is hashed one iteration at a
+	// time as with the scalar one
+	if opts.Format == FormatSHA256 {
+		if opts.Backend != "" && backend != scalarBackend {
+			return nil, fmt.Errorf("the %s backend cannot hash SHA-256", backend.name)
+		}
+		backend = scalarBackend
+	}
+
+	f := startFrontier(opts.Start, workers)
+	if len(opts.Resume) > 0 {
+		f = resumeFrontier(opts.Resume, workers)
+	}
+
+	return &Searcher{
+		opts:    opts,
+		layout:  layout,
+		f:       f,
+		m:       newMatcher(opts.Targets),
+		backend: backend,
+	}, nil
+}
+
+// Search searches for a hash until one is found, or until ctx is done.
+//
+// When searching for targets, it returns the lowest matching iteration from
+// the start, whatever the number of workers. If ctx is done before that is
+// known, all workers stop at a common iteration, so that Next returns an
+// iteration from which a new search continues without testing any commit
+// twice, and the error is that of ctx.
+//
+// When searching with a scorer, it returns the best hash found once ctx is
+// done.
+func (s *Searcher) Search(ctx context.Context) (Result, error) {
+	if s.opts.Scorer != nil {
+		return s.findBest(ctx)
+	}
+	return s.find(ctx)
+}
+
+// Workers returns the number of concurrent workers.
+func (s *Searcher) Workers() int {
+	return len(s.f.next)
+}
+
+// Backend returns the name of the hashing backend used.
+func (s *Searcher) Backend() string {
+	return s.backend.name
+}
+
+// Tested returns the number of commits tested so far. It may be called
+// concurrently with Search.
+func (s *Searcher) Tested() int {
+	return int(s.tested.Load())
+}
+
+// Frontier returns the next iteration to test for each worker. All earlier
+// iterations in the stride of each worker have been tested. It may be called
+// concurrently with Search.
+func (s *Searcher) Frontier() []int {
+	return s.f.snapshot()
+}
+
+// Next returns the lowest iteration not yet tested.
+func (s *Searcher) Next() int {
+	return s.f.min()
+}
+
+// Probability returns the probability of a single commit matching any of the
+// targets, or zero when searching with a scorer.
+func (s *Searcher) Probability() float64 {
+	if s.opts.Scorer != nil {
+		return 0
+	}
+	return s.m.probability()
+}
+
+// hasher hashes the object at successive iterations, as commitHasher does with
+// SHA-1 and sha256Hasher with SHA-256.
+type hasher interface {
+	advance(n, step int)
+	word(k int) uint32
+	hash() []uint32
+	hex() string
+	commit() []byte
+	commitAt(n int) []byte
+}
+
+// newHasher returns a hasher for a worker, and a batch hasher on top of it if
+// the backend hashes batches.
+func (s *Searcher) newHasher() (hasher, *batchHasher) {
+	if s.opts.Format == FormatSHA256 {
+		return newSHA256Hasher(s.layout), nil
+	}
+
+	c := newCommitHasher(s.layout)
+	return c, newBatchHasher(c, s.backend)
+}
+
+func (s *Searcher) find(ctx context.Context) (Result, error) {
+	const pollInterval = 256
+
+	done := make(chan struct{})
+
+	found := make(chan Result)
+
+	var firstN int
+
+	var wg sync.WaitGroup
+
+	f, m := s.f, s.m
+
+	stop := newStopper(len(f.next))
+
+	// stoppedAt is where each worker stopped once ctx was done, above which
+	// its stride is untested
+	stoppedAt := make([]int, len(f.next))
+	for i := range stoppedAt {
+		stoppedAt[i] = math.MaxInt
+	}
+
+	work := func(worker int) {
+		defer wg.Done()
+
+		offset, stepSize := int(f.next[worker].Load()), len(f.next)
+
+		c, b := s.newHasher()
+
+		t := newThrottle(s.opts.CPUPercent)
+
+		var count int
+
+		defer func() {
+			s.tested.Add(int64(count))
+		}()
+
+		// end is the iteration to stop at, moved down once ctx is done
+		end := s.End()
+		registered := false
+
+		defer func() {
+			if !registered {
+				stop.leave()
+			}
+		}()
+
+		// n is the next iteration to test
+		n := offset
+
+		for n >= 0 && n < end {
+			if b != nil && b.fits(n, stepSize, end) {
+				b.hash(n, stepSize)
+
+				// the lowest matching lane is the lowest matching iteration
+				for lane := range b.backend.lanes {
+					if m.keyed && !m.candidate(b.h[m.k][lane]) {
+						continue
+					}
+
+					sum := b.sum(lane)
+
+					if i, ok := m.match(sum[:]); ok {
+						count += lane + 1
+						iteration := n + lane*stepSize
+						found <- Result{Hash: sumHex(sum[:]), Iteration: iteration, Commit: c.commitAt(iteration), Matched: i}
+						return
+					}
+				}
+
+				count += b.backend.lanes
+				n += b.backend.lanes * stepSize
+			} else {
+				c.advance(n, stepSize)
+
+				count++
+
+				if !m.keyed || m.candidate(c.word(m.k)) {
+					if i, ok := m.match(c.hash()); ok {
+						found <- Result{Hash: c.hex(), Iteration: n, Commit: c.commit(), Matched: i}
+						return
+					}
+				}
+
+				n += stepSize
+			}
+
+			if count >= pollInterval {
+				s.tested.Add(int64(count))
+				count = 0
+
+				f.next[worker].Store(int64(n))
+
+				select {
+				case <-done:
+					if n > firstN {
+						return
+					}
+				case <-ctx.Done():
+					if !registered {
+						registered = true
+						end = stop.register(n)
+					}
+				default:
+				}
+
+				if t != nil {
+					t.pause(ctx, done)
+				}
+			}
+		}
+
+		if n >= end {
+			f.next[worker].Store(int64(n))
+
+			if registered {
+				stoppedAt[worker] = n
+			}
+		}
+	}
+
+	for i := range f.next {
+		wg.Add(1)
+		go work(i)
+	}
+
+	go func() {
+		wg.Wait()
+		close(found)
+	}()
+
+	minRes, ok := <-found
+	firstN = minRes.Iteration
+
+	close(done)
+
+	for r := range found {
+		if r.Iteration < minRes.Iteration {
+			minRes = r
+		}
+	}
+
+	if !ok {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+		return Result{}, ErrExhausted
+	}
+
+	// a worker stopped below the match may have missed a lower one, so the
+	// match is only returned once it is known to be the lowest; resuming from
+	// the frontier finds it again
+	if slices.Min(stoppedAt) < minRes.Iteration {
+		return Result{}, ctx.Err()
+	}
+
+	return minRes, nil
+}
+
+func (s *Searcher) findBest(ctx context.Context) (Result, error) {
+	const pollInterval = 256
+
+	improved := make(chan Result)
+
+	var wg sync.WaitGroup
+
+	f, scorer := s.f, s.opts.Scorer
+
+	// bestScore is the best score found by any worker so far
+	var bestScore atomic.Int64
+	bestScore.Store(-1)
+
+	work := func(worker int) {
+		defer wg.Done()
+
+		offset, stepSize := int(f.next[worker].Load()), len(f.next)
+
+		c, b := s.newHasher()
+
+		t := newThrottle(s.opts.CPUPercent)
+
+		best := int(bestScore.Load())
+
+		var count int
+
+		defer func() {
+			s.tested.Add(int64(count))
+		}()
+
+		// consider reports the hash of iteration n if it beats the best so far
+		consider := func(sum []uint32, n int) {
+			score := scorer.score(sum)
+			if score <= best {
+				return
+			}
+
+			best = score
+
+			for {
+				current := bestScore.Load()
+				if int64(score) <= current {
+					best = int(current)
+					return
+				}
+				if bestScore.CompareAndSwap(current, int64(score)) {
+					improved <- Result{Hash: sumHex(sum), Iteration: n, Commit: c.commitAt(n), Score: score}
+					return
+				}
+			}
+		}
+
+		end := s.End()
+
+		// n is the next iteration to test
+		n := offset
+
+		for n >= 0 && n < end {
+			if b != nil && b.fits(n, stepSize, end) {
+				b.hash(n, stepSize)
+
+				for lane := range b.backend.lanes {
+					sum := b.sum(lane)
+					consider(sum[:], n+lane*stepSize)
+				}
+
+				count += b.backend.lanes
+				n += b.backend.lanes * stepSize
+			} else {
+				c.advance(n, stepSize)
+
+				consider(c.hash(), n)
+
+				count++
+				n += stepSize
+			}
+
+			if count >= pollInterval {
+				s.tested.Add(int64(count))
+				count = 0
+
+				f.next[worker].Store(int64(n))
+
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+
+				if t != nil {
+					t.pause(ctx, nil)
+				}
+
+				best = max(best, int(bestScore.Load()))
+			}
+		}
+	}
+
+	for i := range f.next {
+		wg.Add(1)
+		go work(i)
+	}
+
+	go func() {
+		wg.Wait()
+		close(improved)
+	}()
+
+	var bestRes Result
+
+	ok := false
+
+	for r := range improved {
+		if !ok || r.Score > bestRes.Score {
+			bestRes, ok = r, true
+
+			if s.opts.Improved != nil {
+				s.opts.Improved(r)
+			}
+		}
+	}
+
+	if !ok {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+		return Result{}, ErrExhausted
+	}
+
+	return bestRes, nil
+}
+
+// End returns the iteration the search stops before, which is Options.End or
+// the number of iterations there are with NonceTimestamp, whichever is lower,
+// or math.MaxInt if there is no end.
+func (s *Searcher) End() int {
+	end := math.MaxInt
+	if s.opts.End > 0 {
+		end = s.opts.End
+	}
+	if s.layout.end > 0 {
+		end = min(end, s.layout.end)
+	}
+	return end
+}
+
+// numWorkers returns the default number of concurrent workers.
+func numWorkers() int {
+	return min(runtime.GOMAXPROCS(0), runtime.NumCPU())
+}
+
+// Estimate returns percentile estimates of the time in seconds to find a
+// match, given the probability p of a single hash matching.
+func Estimate(hashesPerSecond, p float64) (p10, p50, p90 float64) {
+	if hashesPerSecond <= 0 {
+		return 0, 0, 0
+	}
+
+	quantile := func(q float64) (seconds float64) {
+		// k is the number of attempts needed to find one match at probability q
+		//
+		// math.Log1p(-p) is the same as math.Log(1-p) but accurate for small
+		// values. It prevents 1-p from collapsing to 1.0 for longer hash prefixes,
+		// which would cause math.Log(1-p) to return 0 and k to be infinite, in turn
+		// resulting in mangled estimates.
+		k := math.Log(1-q) / math.Log1p(-p)
+
+		// t is the number of seconds needed to run k attempts
+		t := k / hashesPerSecond
+
+		return t
+	}
+
+	return quantile(0.1), quantile(0.5), quantile(0.9)
+}
+
+// Chance returns the probability of having found at least one match after the
+// given number of attempts, given the probability p of a single hash matching.
+func Chance(attempts int, p float64) float64 {
+	return -math.Expm1(float64(attempts) * math.Log1p(-p))
+}
@@ -0,0 +1,677 @@
+package vanity
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const commit = `tree 0000000000000000000000000000000000000000
+author Author Name <author@example.com> 1577872800 +0000
+committer Committer Name <committer@example.com> 1577876400 +0100
+
+Message
+`
+
+const tag = `object 0000000000000000000000000000000000000000
+type commit
+tag v1.0.0
+tagger Tagger Name <tagger@example.com> 1577876400 +0100
+
+Release 1.0.0
+`
+
+func TestSearch(t *testing.T) {
+	for _, tc := range []struct {
+		desc          string
+		startN        int
+		endN          int
+		patterns      []string
+		wantHash      string
+		wantIteration int
+		wantNewCommit []byte
+		wantMatched   int
+		wantOK        bool
+	}{
+		{
+			desc:          "Start at 0th iteration",
+			patterns:      []string{AffixPattern("0", "")},
+			startN:        0,
+			wantHash:      "034c4f788c4a7522a75e1b86ee3c24eee630e822",
+			wantIteration: 16,
+			wantNewCommit: []byte(`tree 0000000000000000000000000000000000000000
+author Author Name <author@example.com> 1577872800 +0000
+committer Committer Name <committer@example.com> 1577876400 +0100
+foo 16
+
+Message
+`),
+			wantOK: true,
+		},
+		{
+			desc:          "Start at final iteration",
+			patterns:      []string{AffixPattern("0", "")},
+			startN:        16,
+			wantHash:      "034c4f788c4a7522a75e1b86ee3c24eee630e822",
+			wantIteration: 16,
+			wantNewCommit: []byte(`tree 0000000000000000000000000000000000000000
+author Author Name <author@example.com> 1577872800 +0000
+committer Committer Name <committer@example.com> 1577876400 +0100
+foo 16
+
+Message
+`),
+			wantOK: true,
+		},
+		{
+			desc:          "Start after would-be final iteration",
+			patterns:      []string{AffixPattern("0", "")},
+			startN:        17,
+			wantHash:      "0457314be0b9283e18224b8dfad77741d9f41cdf",
+			wantIteration: 43,
+			wantNewCommit: []byte(`tree 0000000000000000000000000000000000000000
+author Author Name <author@example.com> 1577872800 +0000
+committer Committer Name <committer@example.com> 1577876400 +0100
+foo 43
+
+Message
+`),
+			wantOK: true,
+		},
+		{
+			desc:          "Suffix",
+			patterns:      []string{AffixPattern("", "0")},
+			startN:        0,
+			wantHash:      "ff5aab44e03775ab21f17a1a46b1363d32c508b0",
+			wantIteration: 5,
+			wantNewCommit: []byte(`tree 0000000000000000000000000000000000000000
+author Author Name <author@example.com> 1577872800 +0000
+committer Committer Name <committer@example.com> 1577876400 +0100
+foo 5
+
+Message
+`),
+			wantOK: true,
+		},
+		{
+			desc:          "Several targets",
+			patterns:      []string{AffixPattern("1", ""), AffixPattern("0", ""), AffixPattern("", "0")},
+			startN:        0,
+			wantHash:      "ff5aab44e03775ab21f17a1a46b1363d32c508b0",
+			wantIteration: 5,
+			wantNewCommit: []byte(`tree 0000000000000000000000000000000000000000
+author Author Name <author@example.com> 1577872800 +0000
+committer Committer Name <committer@example.com> 1577876400 +0100
+foo 5
+
+Message
+`),
+			wantMatched: 2,
+			wantOK:      true,
+		},
+		{
+			desc:          "End before match",
+			patterns:      []string{AffixPattern("0", "")},
+			startN:        0,
+			endN:          16,
+			wantHash:      "",
+			wantIteration: 0,
+			wantNewCommit: nil,
+			wantOK:        false,
+		},
+		{
+			desc:          "End after match",
+			patterns:      []string{AffixPattern("0", "")},
+			startN:        0,
+			endN:          17,
+			wantHash:      "034c4f788c4a7522a75e1b86ee3c24eee630e822",
+			wantIteration: 16,
+			wantNewCommit: []byte(`tree 0000000000000000000000000000000000000000
+author Author Name <author@example.com> 1577872800 +0000
+committer Committer Name <committer@example.com> 1577876400 +0100
+foo 16
+
+Message
+`),
+			wantOK: true,
+		},
+		{
+			desc:          "Start at maxint iteration",
+			patterns:      []string{AffixPattern("1", "")},
+			startN:        math.MaxInt,
+			wantHash:      "",
+			wantIteration: 0,
+			wantNewCommit: nil,
+			wantOK:        false,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			for _, backend := range Backends() {
+				t.Run(backend, func(t *testing.T) {
+					var targets []Target
+					for _, pattern := range tc.patterns {
+						targets = append(targets, newTarget(pattern))
+					}
+
+					s, err := NewSearcher(Options{Commit: []byte(commit), Key: "foo", Targets: targets, Start: tc.startN, End: tc.endN, Backend: backend})
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					res, err := s.Search(context.Background())
+
+					if got, want := res.Hash, tc.wantHash; got != want {
+						t.Errorf("hash = %q, want %q", got, want)
+					}
+
+					if got, want := res.Iteration, tc.wantIteration; got != want {
+						t.Errorf("iteration = %d, want %d", got, want)
+					}
+
+					if !bytes.Equal(res.Commit, tc.wantNewCommit) {
+						t.Errorf("new commit is:\n%s\n\nwant:\n%s", res.Commit, tc.wantNewCommit)
+					}
+
+					if got, want := res.Matched, tc.wantMatched; got != want {
+						t.Errorf("matched = %d, want %d", got, want)
+					}
+
+					if tc.wantOK && err != nil {
+						t.Errorf("got error %v, want none", err)
+					}
+
+					if !tc.wantOK && !errors.Is(err, ErrExhausted) {
+						t.Errorf("got error %v, want %v", err, ErrExhausted)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestSearchBest(t *testing.T) {
+	for _, best := range []string{"zeros", "f"} {
+		t.Run(best, func(t *testing.T) {
+			scorer, err := NewScorer(best)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var improvements []Result
+
+			s, err := NewSearcher(Options{
+				Commit: []byte(commit),
+				Key:    "foo",
+				Scorer: scorer,
+				Improved: func(r Result) {
+					improvements = append(improvements, r)
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			res, err := s.Search(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := res.Hash, commitHash(res.Commit); got != want {
+				t.Errorf("hash = %q, want %q", got, want)
+			}
+
+			if want := fmt.Sprintf("\nfoo %d\n", res.Iteration); !bytes.Contains(res.Commit, []byte(want)) {
+				t.Errorf("new commit does not contain header %q:\n%s", want, res.Commit)
+			}
+
+			words, _ := hashPatternWords(res.Hash)
+
+			if got, want := res.Score, scorer.score(words[:5]); got != want {
+				t.Errorf("score = %d, want %d", got, want)
+			}
+
+			if len(improvements) == 0 || improvements[len(improvements)-1].Hash != res.Hash {
+				t.Errorf("last improvement is not the result %s", res.Hash)
+			}
+
+			if tested := s.Tested(); tested <= res.Iteration {
+				t.Errorf("tested = %d, want more than iteration %d", tested, res.Iteration)
+			}
+		})
+	}
+}
+
+// commitHash returns the hash of the given commit object in hex.
+func commitHash(commit []byte) string {
+	return objectHash(TypeCommit, commit)
+}
+
+// objectHash returns the hash of the given object of the given type in hex.
+func objectHash(typ string, object []byte) string {
+	return fmt.Sprintf("%x", sha1.Sum(fmt.Appendf(nil, "%s %d\x00%s", typ, len(object), object)))
+}
+
+func TestSearchWorkerCounts(t *testing.T) {
+	// a few worker counts dividing the lanes of every backend, or not, with
+	// BenchmarkSearchWorkerCounts covering all up to 16
+	checkWorkerCounts(t, []int{0, 123456}, []int{1, 3, 8, 13})
+}
+
+// BenchmarkSearchWorkerCounts runs TestSearchWorkerCounts for every number of
+// workers up to 16 and more starting iterations, which is too slow to run with
+// the other tests.
+func BenchmarkSearchWorkerCounts(b *testing.B) {
+	var workers []int
+	for n := 1; n <= 16; n++ {
+		workers = append(workers, n)
+	}
+
+	for b.Loop() {
+		checkWorkerCounts(b, []int{0, 1, 9990, 123456}, workers)
+	}
+}
+
+// checkWorkerCounts checks that searches from each of the given starting
+// iterations find the same result with each number of workers and backend.
+func checkWorkerCounts(tb testing.TB, starts, workerCounts []int) {
+	targets := []Target{
+		newTarget(AffixPattern("c0ff", "")),
+		newTarget(AffixPattern("", "dec")),
+		newTarget(AffixPattern("ba", "d")),
+	}
+
+	for _, tc := range testLayouts() {
+		for _, start := range starts {
+			var want Result
+
+			for _, backend := range Backends() {
+				for _, workers := range workerCounts {
+					opts := tc.opts
+					opts.Targets, opts.Start, opts.Workers, opts.Backend = targets, start, workers, backend
+
+					s, err := NewSearcher(opts)
+					if err != nil {
+						tb.Fatal(err)
+					}
+
+					res, err := s.Search(context.Background())
+					if err != nil {
+						tb.Fatal(err)
+					}
+
+					if want.Commit == nil {
+						want = res
+						continue
+					}
+
+					if res.Iteration != want.Iteration || res.Hash != want.Hash || res.Matched != want.Matched || !bytes.Equal(res.Commit, want.Commit) {
+						tb.Errorf("[%s, start %d, %s, %d workers] got %s at iteration %d, want %s at iteration %d", tc.name, start, backend, workers, res.Hash, res.Iteration, want.Hash, want.Iteration)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSearchWhitespace(t *testing.T) {
+	for _, backend := range Backends() {
+		t.Run(backend, func(t *testing.T) {
+			s, err := NewSearcher(Options{
+				Commit:  []byte(commit),
+				Nonce:   NonceWhitespace,
+				Targets: []Target{newTarget(AffixPattern("c0ff", ""))},
+				Backend: backend,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := s.Search(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := res.Hash, commitHash(res.Commit); got != want {
+				t.Errorf("hash = %q, want %q", got, want)
+			}
+
+			if !strings.HasPrefix(res.Hash, "c0ff") {
+				t.Errorf("hash %q does not start with c0ff", res.Hash)
+			}
+
+			wantPrefix, _, _ := strings.Cut(commit, "Message")
+			wantMessage := "Message" + string(whitespace.append(nil, res.Iteration)) + "\n"
+
+			if got, want := string(res.Commit), wantPrefix+wantMessage; got != want {
+				t.Errorf("new commit is:\n%q\n\nwant:\n%q", got, want)
+			}
+		})
+	}
+}
+
+func TestSearchTrailer(t *testing.T) {
+	for _, backend := range Backends() {
+		t.Run(backend, func(t *testing.T) {
+			s, err := NewSearcher(Options{
+				Commit:  []byte(commit),
+				Nonce:   NonceTrailer,
+				Targets: []Target{newTarget(AffixPattern("c0ff", ""))},
+				Backend: backend,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := s.Search(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := res.Hash, commitHash(res.Commit); got != want {
+				t.Errorf("hash = %q, want %q", got, want)
+			}
+
+			if !strings.HasPrefix(res.Hash, "c0ff") {
+				t.Errorf("hash %q does not start with c0ff", res.Hash)
+			}
+
+			wantPrefix, _, _ := strings.Cut(commit, "Message")
+			wantMessage := fmt.Sprintf("Message\n\nVanity-Nonce: %d\n", res.Iteration)
+
+			if got, want := string(res.Commit), wantPrefix+wantMessage; got != want {
+				t.Errorf("new commit is:\n%q\n\nwant:\n%q", got, want)
+			}
+		})
+	}
+}
+
+func TestSearchTag(t *testing.T) {
+	for _, tc := range []struct {
+		nonce string
+		want  func(iteration int) string
+	}{
+		{NonceTrailer, func(n int) string {
+			return fmt.Sprintf("%s\nVanity-Nonce: %d\n", tag, n)
+		}},
+		{NonceTimestamp, func(n int) string {
+			return strings.Replace(tag, "1577876400", strconv.Itoa(1577872800+n), 1)
+		}},
+	} {
+		t.Run(tc.nonce, func(t *testing.T) {
+			s, err := NewSearcher(Options{
+				Commit:     []byte(tag),
+				Type:       TypeTag,
+				Key:        "c0ff",
+				Nonce:      tc.nonce,
+				TimeWindow: time.Hour,
+				Targets:    []Target{newTarget(AffixPattern("c0f", ""))},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := s.Search(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := res.Hash, objectHash(TypeTag, res.Commit); got != want {
+				t.Errorf("hash = %q, want %q", got, want)
+			}
+
+			if !strings.HasPrefix(res.Hash, "c0f") {
+				t.Errorf("hash %q does not start with c0f", res.Hash)
+			}
+
+			if got, want := string(res.Commit), tc.want(res.Iteration); got != want {
+				t.Errorf("new tag is:\n%q\n\nwant:\n%q", got, want)
+			}
+		})
+	}
+}
+
+func TestSearchTagMktag(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	dir := t.TempDir()
+
+	git := func(stdin string, args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Stdin = strings.NewReader(stdin)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	git("", "init", "--quiet")
+
+	// git mktag only accepts tags of objects it has
+	tree := git("", "mktree")
+	object := git(strings.Replace(commit, strings.Repeat("0", 40), tree, 1), "hash-object", "-t", "commit", "-w", "--stdin")
+
+	for _, nonce := range []string{NonceTrailer, NonceWhitespace, NonceTimestamp} {
+		t.Run(nonce, func(t *testing.T) {
+			s, err := NewSearcher(Options{
+				Commit:     []byte(strings.Replace(tag, strings.Repeat("0", 40), object, 1)),
+				Type:       TypeTag,
+				Nonce:      nonce,
+				TimeWindow: time.Hour,
+				Targets:    []Target{newTarget(AffixPattern("c0f", ""))},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := s.Search(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := git(string(res.Commit), "mktag"), res.Hash; got != want {
+				t.Errorf("git mktag wrote %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestSearchUpdatesFrontier(t *testing.T) {
+	s, err := NewSearcher(Options{
+		Commit:  []byte(commit),
+		Key:     "foo",
+		Targets: []Target{newTarget(AffixPattern("c0ff", ""))},
+		Workers: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Search(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, n := range s.Frontier() {
+		if n%3 != i {
+			t.Errorf("next[%d] = %d, want it in the stride of worker %d", i, n, i)
+		}
+	}
+
+	if s.Next() == 0 {
+		t.Error("frontier did not advance")
+	}
+}
+
+func TestSearchCancel(t *testing.T) {
+	for _, backend := range Backends() {
+		for _, workers := range []int{1, 3, 8} {
+			t.Run(fmt.Sprintf("%s/%d workers", backend, workers), func(t *testing.T) {
+				s, err := NewSearcher(Options{
+					Commit:  []byte(commit),
+					Key:     "foo",
+					Targets: []Target{newTarget(AffixPattern("0000000000", ""))},
+					Workers: workers,
+					Backend: backend,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				ctx, cancel := context.WithCancel(context.Background())
+
+				time.AfterFunc(50*time.Millisecond, cancel)
+
+				if _, err := s.Search(ctx); !errors.Is(err, context.Canceled) {
+					t.Fatalf("got error %v, want %v", err, context.Canceled)
+				}
+
+				stopN := s.Next()
+
+				if stopN == 0 {
+					t.Fatal("frontier did not advance")
+				}
+
+				// each worker stops at the first iteration of its stride at or above
+				// the common stopping point
+				for i, n := range s.Frontier() {
+					if n%workers != i%workers || n < stopN || n >= stopN+workers {
+						t.Errorf("next[%d] = %d, want the first iteration of stride %d at or above %d", i, n, i, stopN)
+					}
+				}
+
+				if got, want := s.Tested(), stopN; got != want {
+					t.Errorf("tested = %d, want %d", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestSearchThrottled(t *testing.T) {
+	search := func(cpuPercent int) Result {
+		s, err := NewSearcher(Options{
+			Commit:     []byte(commit),
+			Key:        "foo",
+			Targets:    []Target{newTarget(AffixPattern("c0ff", ""))},
+			Workers:    2,
+			CPUPercent: cpuPercent,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := s.Search(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return res
+	}
+
+	if got, want := search(50).Iteration, search(0).Iteration; got != want {
+		t.Errorf("iteration = %d, want %d", got, want)
+	}
+}
+
+func TestSearchResume(t *testing.T) {
+	s, err := NewSearcher(Options{
+		Commit:  []byte(commit),
+		Key:     "foo",
+		Targets: []Target{newTarget(AffixPattern("0", ""))},
+		Start:   5,
+		Resume:  []int{17, 18},
+		Workers: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := s.Search(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := res.Iteration, 43; got != want {
+		t.Errorf("iteration = %d, want %d", got, want)
+	}
+}
+
+func TestNewSearcherErrors(t *testing.T) {
+	scorer, err := NewScorer("zeros")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targets := []Target{newTarget(AffixPattern("c0ffee", ""))}
+
+	for n, opts := range []Options{
+		{Commit: []byte(commit), Key: "foo"},
+		{Commit: []byte(commit), Key: "foo", Targets: targets, Scorer: scorer},
+		{Commit: []byte(commit), Key: "foo", Targets: []Target{{}}},
+		{Commit: []byte(commit), Key: "tree", Targets: targets},
+		{Commit: []byte(commit), Key: "", Targets: targets},
+		{Commit: []byte(commit), Key: "foo", Targets: targets, Start: -1},
+		{Commit: []byte(commit), Key: "foo", Targets: targets, End: -1},
+		{Commit: []byte(commit), Key: "foo", Targets: targets, Workers: -1},
+		{Commit: []byte(commit), Key: "foo", Targets: targets, Backend: "unknown"},
+		{Commit: []byte(commit), Key: "foo", Targets: targets, CPUPercent: -1},
+		{Commit: []byte(commit), Key: "foo", Targets: targets, CPUPercent: 101},
+		{Commit: []byte(commit), Key: "foo", Targets: targets, Nonce: "unknown"},
+		{Commit: []byte(commit), Key: "foo", Targets: targets, Format: "unknown"},
+		{Commit: []byte(commit), Key: "foo", Targets: targets, Format: FormatSHA256},
+		{Commit: []byte(commit), Key: "foo", Targets: []Target{newTarget(AffixPatternFor(FormatSHA256, "c0ffee", ""))}, Format: FormatSHA256, Backend: "generic"},
+		{Commit: []byte("tree 0000000000000000000000000000000000000000\n\n"), Targets: targets, Nonce: NonceWhitespace},
+		{Commit: []byte("tree 0000000000000000000000000000000000000000\n"), Key: "foo", Targets: targets},
+	} {
+		if _, err := NewSearcher(opts); err == nil {
+			t.Errorf("[%d] NewSearcher returned no error", n)
+		}
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	for _, backend := range Backends() {
+		b.Run(backend, func(b *testing.B) {
+			for b.Loop() {
+				s, err := NewSearcher(Options{Commit: []byte(commit), Key: "c0ffee", Targets: []Target{newTarget(AffixPattern("c0ffee", ""))}, Backend: backend})
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				s.Search(context.Background())
+			}
+		})
+	}
+}
+
+func BenchmarkSearchPrefixSuffix(b *testing.B) {
+	// targets keyed on different words, which are not rejected on a word
+	targets := []Target{newTarget(AffixPattern("c0ffee", "")), newTarget(AffixPattern("", "c0ffee"))}
+
+	for _, backend := range Backends() {
+		b.Run(backend, func(b *testing.B) {
+			for b.Loop() {
+				s, err := NewSearcher(Options{Commit: []byte(commit), Key: "c0ffee", Targets: targets, Backend: backend})
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				s.Search(context.Background())
+			}
+		})
+	}
+}
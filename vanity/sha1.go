@@ -0,0 +1,230 @@
+package vanity
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+)
+
+// commitHasher hashes a commit holding the iteration number, reusing the work
+// done for the previous iteration where possible.
+type commitHasher struct {
+	objectBuffer
+
+	// lastSum is the state after the whole blocks of the prefix
+	lastSum [5]uint32
+
+	// sum is the hash at the current iteration
+	sum [5]uint32
+
+	// v is the offset in buf of the word holding the last digit, which
+	// changes with every iteration
+	v int
+
+	// pre is the state before the block holding the last digit, and partial
+	// the hashing of that block as far as it can be done ahead, which is
+	// redone when next needed if stale
+	pre     [5]uint32
+	partial sha1Partial
+	stale   bool
+}
+
+// newCommitHasher returns a hasher for the commit with the iteration numbers
+// in the given layout.
+func newCommitHasher(l *layout) *commitHasher {
+	return &commitHasher{objectBuffer: newObjectBuffer(l)}
+}
+
+// advance moves the hasher to iteration n, given that it was at iteration
+// n-step.
+func (c *commitHasher) advance(n, step int) {
+	changed, laidOut := c.objectBuffer.advance(n, step)
+
+	if laidOut {
+		c.lastSum = sha1Blocks(sha1Init, c.whole())
+
+		lastDigit := c.lastDigit()
+		c.v = lastDigit - lastDigit%4
+
+		c.precompute(0)
+
+		return
+	}
+
+	// anything before the word with the last digit changes only on carries
+	if changed < c.v {
+		c.precompute(changed)
+	}
+}
+
+// precompute redoes the work ahead for the word with the last digit, given
+// that nothing before the given offset has changed since it was last done, or
+// that anything may have changed if it is zero.
+func (c *commitHasher) precompute(changed int) {
+	block := c.v - c.v%sha1.BlockSize
+
+	if changed < block || changed == 0 {
+		c.pre = sha1Blocks(c.lastSum, c.buf[:block])
+	}
+
+	c.stale = true
+}
+
+// refresh redoes the hashing ahead of the block holding the last digit if it
+// is stale.
+func (c *commitHasher) refresh() {
+	if c.stale {
+		block := c.v - c.v%sha1.BlockSize
+		c.partial.reset(c.pre, (*[sha1.BlockSize]byte)(c.buf[block:]), c.v%sha1.BlockSize/4)
+		c.stale = false
+	}
+}
+
+// word returns word k of the hash of the commit at the current iteration,
+// running only the rounds of the last block needed for it. Most candidates can
+// be rejected on a single word, leaving the full hash for the few that cannot.
+func (c *commitHasher) word(k int) uint32 {
+	c.refresh()
+
+	buf := c.buf
+
+	v := binary.BigEndian.Uint32(buf[c.v:])
+
+	end := c.v - c.v%sha1.BlockSize + sha1.BlockSize
+	if end == len(buf) {
+		return c.partial.word(v, k)
+	}
+
+	last := len(buf) - sha1.BlockSize
+	h := sha1Blocks(c.partial.block(v), buf[end:last])
+	return sha1BlockWord(h, (*[sha1.BlockSize]byte)(buf[last:]), k)
+}
+
+// hash returns the hash of the commit at the current iteration. The returned
+// sum is valid until the hasher is advanced.
+func (c *commitHasher) hash() []uint32 {
+	c.refresh()
+
+	buf := c.buf
+
+	v := binary.BigEndian.Uint32(buf[c.v:])
+
+	end := c.v - c.v%sha1.BlockSize + sha1.BlockSize
+	c.sum = sha1Blocks(c.partial.block(v), buf[end:])
+
+	return c.sum[:]
+}
+
+// hex returns the hash last returned by hash in hex.
+func (c *commitHasher) hex() string {
+	return sumHex(c.sum[:])
+}
+
+// batchHasher hashes a batch of iterations at once, one in each lane of a
+// backend, on top of a commitHasher that preparing the lanes moves along.
+type batchHasher struct {
+	c       *commitHasher
+	backend *sha1Backend
+
+	// h holds the hash of each lane once hashed, and w is the block hashed
+	h [5][maxLanes]uint32
+	w [16][maxLanes]uint32
+}
+
+// newBatchHasher returns a hasher hashing batches with the backend on top of
+// c, or nil if the backend hashes one iteration at a time.
+func newBatchHasher(c *commitHasher, backend *sha1Backend) *batchHasher {
+	if backend.block == nil {
+		return nil
+	}
+	return &batchHasher{c: c, backend: backend}
+}
+
+// fits reports whether the iterations n, n+step and so on, one per lane, are
+// all below end, in the same run and of the same number of digits, as a batch
+// must be.
+func (b *batchHasher) fits(n, step, end int) bool {
+	if (end-1-n)/step < b.backend.lanes-1 {
+		return false
+	}
+	l, last := b.c.layout, n+(b.backend.lanes-1)*step
+	return l.run(n) == l.run(last) && l.len(l.value(n)) == l.len(l.value(last))
+}
+
+// hash hashes the iterations n, n+step and so on, one per lane, given that the
+// underlying hasher was at iteration n-step. It is left at the last of them.
+// Word k of the hash in lane i is then h[k][i].
+func (b *batchHasher) hash(n, step int) {
+	c := b.c
+
+	for lane := range b.backend.lanes {
+		c.advance(n+lane*step, step)
+
+		block := c.buf[c.v-c.v%sha1.BlockSize:]
+
+		for k := range b.h {
+			b.h[k][lane] = c.pre[k]
+		}
+
+		for i := range b.w {
+			b.w[i][lane] = binary.BigEndian.Uint32(block[i*4:])
+		}
+	}
+
+	b.backend.block(&b.h, &b.w)
+
+	// the blocks after the one with the last digit are the same in all lanes
+	buf := c.buf
+
+	for end := c.v - c.v%sha1.BlockSize + sha1.BlockSize; end < len(buf); end += sha1.BlockSize {
+		for i := range b.w {
+			w := binary.BigEndian.Uint32(buf[end+i*4:])
+			for lane := range b.backend.lanes {
+				b.w[i][lane] = w
+			}
+		}
+
+		b.backend.block(&b.h, &b.w)
+	}
+}
+
+// sum returns the hash in the given lane.
+func (b *batchHasher) sum(lane int) [5]uint32 {
+	var sum [5]uint32
+	for k := range sum {
+		sum[k] = b.h[k][lane]
+	}
+	return sum
+}
+
+// sumHex returns the hash of any size in hex.
+func sumHex(sum []uint32) string {
+	b := make([]byte, len(sum)*4)
+	for i, w := range sum {
+		binary.BigEndian.PutUint32(b[i*4:], w)
+	}
+	return hex.EncodeToString(b)
+}
+
+func headTail(commit []byte) (head, tail []byte, err error) {
+	idx := bytes.Index(commit, []byte("\n\n"))
+	if idx == -1 {
+		return nil, nil, errors.New("cannot parse commit")
+	}
+	return commit[:idx], commit[idx:], nil
+}
+
+func trimHeader(head []byte, header string) []byte {
+	idx := bytes.LastIndex(head, []byte("\n"))
+	if idx == -1 {
+		return head
+	}
+
+	if bytes.HasPrefix(head[idx+1:], []byte(header)) {
+		return head[:idx]
+	}
+
+	return head
+}
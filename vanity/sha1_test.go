@@ -0,0 +1,399 @@
+package vanity
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestHeadTail(t *testing.T) {
+	wantHead := []byte(`tree 0000000000000000000000000000000000000000
+author Author Name <author@example.com> 1577872800 +0000
+committer Committer Name <committer@example.com> 1577876400 +0100`)
+
+	wantTail := []byte("\n\nMessage\n")
+
+	head, tail, err := headTail([]byte(commit))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(head, wantHead) {
+		t.Errorf("head is %s, want %s", head, wantHead)
+	}
+
+	if !bytes.Equal(tail, wantTail) {
+		t.Errorf("tail is %s, want %s", tail, wantTail)
+	}
+
+	if _, _, err := headTail([]byte("tree 0000000000000000000000000000000000000000\n")); err == nil {
+		t.Error("no error for commit without message")
+	}
+}
+
+func TestSHA1Block(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+
+	for n := range 1000 {
+		message := make([]byte, r.IntN(4*sha1.BlockSize))
+		for i := range message {
+			message[i] = byte(r.Uint32())
+		}
+
+		// hash the whole blocks first and the rest padded, as commitHasher does,
+		// continuing from the midstate in between
+		whole := len(message) - len(message)%sha1.BlockSize
+
+		midstate := sha1Blocks(sha1Init, message[:whole])
+
+		sum := sha1Blocks(midstate, paddedNSizeTailBlock(message[whole:], 0, nil, len(message)))
+
+		var gotSum [sha1.Size]byte
+		for i, w := range sum {
+			binary.BigEndian.PutUint32(gotSum[i*4:], w)
+		}
+
+		if wantSum := sha1.Sum(message); gotSum != wantSum {
+			t.Fatalf("[%d] got %x, want %x for %d bytes", n, gotSum, wantSum, len(message))
+		}
+	}
+}
+
+func TestSHA1BlockWord(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+
+	for n := range 1000 {
+		var h [5]uint32
+		for i := range h {
+			h[i] = r.Uint32()
+		}
+
+		var block [sha1.BlockSize]byte
+		for i := range block {
+			block[i] = byte(r.Uint32())
+		}
+
+		want := sha1Block(h, &block)
+
+		for k := range want {
+			if got := sha1BlockWord(h, &block, k); got != want[k] {
+				t.Fatalf("[%d] word %d is %08x, want %08x", n, k, got, want[k])
+			}
+		}
+	}
+}
+
+func TestSHA1Partial(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+
+	for n := range 1000 {
+		var h [5]uint32
+		for i := range h {
+			h[i] = r.Uint32()
+		}
+
+		var block [sha1.BlockSize]byte
+		for i := range block {
+			block[i] = byte(r.Uint32())
+		}
+
+		j := r.IntN(16)
+
+		var p sha1Partial
+		p.reset(h, &block, j)
+
+		for range 3 {
+			v := r.Uint32()
+			binary.BigEndian.PutUint32(block[j*4:], v)
+
+			want := sha1Block(h, &block)
+
+			if got := p.block(v); got != want {
+				t.Fatalf("[%d] word %d set to %08x: got %08x, want %08x", n, j, v, got, want)
+			}
+
+			for k := range want {
+				if got := p.word(v, k); got != want[k] {
+					t.Fatalf("[%d] word %d set to %08x: word %d is %08x, want %08x", n, j, v, k, got, want[k])
+				}
+			}
+		}
+	}
+}
+
+func TestPaddedNSizeTailBlock(t *testing.T) {
+	for messageLen := range 3 * sha1.BlockSize {
+		for headLen := range sha1.BlockSize {
+			head := bytes.Repeat([]byte("h"), headLen)
+			message := bytes.Repeat([]byte("x"), messageLen)
+
+			nBytes := []byte("12345")
+
+			objectSize := len(head) + len(nBytes) + len(message)
+
+			block := paddedNSizeTailBlock(head, len(nBytes), message, objectSize)
+
+			if len(block)%sha1.BlockSize != 0 {
+				t.Fatalf("[%d, %d] padded size is %d, want a multiple of %d", headLen, messageLen, len(block), sha1.BlockSize)
+			}
+
+			copy(block[len(head):], nBytes)
+
+			var gotSum [sha1.Size]byte
+
+			for i, w := range sha1Blocks(sha1Init, block) {
+				binary.BigEndian.PutUint32(gotSum[i*4:], w)
+			}
+
+			wantSum := sha1.Sum(slices.Concat(head, nBytes, message))
+
+			if gotSum != wantSum {
+				t.Errorf("[%d, %d] got %x, want %x", headLen, messageLen, gotSum, wantSum)
+			}
+		}
+	}
+}
+
+func TestCommitHasher(t *testing.T) {
+	for _, tc := range testLayouts() {
+		t.Run(tc.name, func(t *testing.T) {
+			l, err := newLayout(tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the steps cross digit lengths, rebuilding the padded block, and
+			// carry into earlier words and blocks
+			for _, step := range []int{1, 7, 997} {
+				c := newCommitHasher(l)
+
+				for n := 0; n < 200*step; n += step {
+					c.advance(n, step)
+
+					sum := [5]uint32(c.hash())
+
+					if got, want := c.hex(), commitHash(c.commit()); got != want {
+						t.Fatalf("[%d, %d] hash is %s, want %s", step, n, got, want)
+					}
+
+					if got, want := c.commit(), l.commitAt(n); !bytes.Equal(got, want) {
+						t.Fatalf("[%d, %d] commit is %q, want %q", step, n, got, want)
+					}
+
+					for k := range sum {
+						if got, want := c.word(k), sum[k]; got != want {
+							t.Errorf("[%d, %d] word(%d) = %08x, want %08x", step, n, k, got, want)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// testOptions returns the options of a search of the test commit with the
+// given nonce, with passes through a small time window for NonceTimestamp.
+func testOptions(nonce string) Options {
+	return Options{
+		Commit:         []byte(commit),
+		Key:            "foo",
+		Nonce:          nonce,
+		TimeWindow:     time.Minute,
+		TimeAuthor:     true,
+		TimeWhitespace: true,
+	}
+}
+
+// testLayout is a layout to test the hashers with.
+type testLayout struct {
+	name string
+	opts Options
+}
+
+// testLayouts returns the options of searches of the test commit with each
+// nonce and encoding, and with a fixed width.
+func testLayouts() []testLayout {
+	var layouts []testLayout
+
+	for _, nonce := range Nonces() {
+		layouts = append(layouts, testLayout{nonce, testOptions(nonce)})
+	}
+
+	for _, encoding := range Encodings()[1:] {
+		opts := testOptions(NonceHeader)
+		opts.Encoding = encoding
+		layouts = append(layouts, testLayout{encoding, opts})
+	}
+
+	opts := testOptions(NonceTrailer)
+	opts.Width = 12
+	layouts = append(layouts, testLayout{"fixed width", opts})
+
+	return layouts
+}
+
+func TestBatchHasher(t *testing.T) {
+	for _, backend := range sha1Backends {
+		if backend.block == nil {
+			continue
+		}
+
+		for _, tc := range testLayouts() {
+			t.Run(backend.name+"/"+tc.name, func(t *testing.T) {
+				l, err := newLayout(tc.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// the steps cross digit lengths, leaving some iterations to the
+				// scalar path, and carry into earlier words and blocks
+				for _, step := range []int{1, 7, 997} {
+					c := newCommitHasher(l)
+					b := newBatchHasher(c, backend)
+
+					scalar := newCommitHasher(l)
+
+					for n := 0; n < 500*step; {
+						if !b.fits(n, step, math.MaxInt) {
+							c.advance(n, step)
+							scalar.advance(n, step)
+							n += step
+							continue
+						}
+
+						b.hash(n, step)
+
+						for lane := range backend.lanes {
+							iteration := n + lane*step
+
+							scalar.advance(iteration, step)
+
+							sum := b.sum(lane)
+
+							if got, want := sum, [5]uint32(scalar.hash()); got != want {
+								t.Fatalf("[%d, %d] hash is %08x, want %08x", step, iteration, got, want)
+							}
+
+							if got, want := sumHex(sum[:]), commitHash(c.commitAt(iteration)); got != want {
+								t.Fatalf("[%d, %d] hash is %s, want %s", step, iteration, got, want)
+							}
+						}
+
+						n += backend.lanes * step
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestBatchHasherFits(t *testing.T) {
+	b := &batchHasher{c: newCommitHasher(fixedLayout(nil, nil, decimal)), backend: &sha1Backend{lanes: 4}}
+
+	for n, tc := range []struct {
+		n, step, end int
+		want         bool
+	}{
+		{0, 1, math.MaxInt, true},
+		{6, 1, math.MaxInt, true},
+		{7, 1, math.MaxInt, false},
+		{10, 20, math.MaxInt, true},
+		{10, 20, 71, true},
+		{10, 20, 70, false},
+		{100, 300, math.MaxInt, false},
+		{math.MaxInt - 3, 1, math.MaxInt, false},
+		{math.MaxInt / 4, math.MaxInt / 3, math.MaxInt, false},
+	} {
+		if got := b.fits(tc.n, tc.step, tc.end); got != tc.want {
+			t.Errorf("[%d] fits(%d, %d, %d) = %t, want %t", n, tc.n, tc.step, tc.end, got, tc.want)
+		}
+	}
+}
+
+func BenchmarkCommitHasher(b *testing.B) {
+	l, err := newLayout(testOptions(NonceHeader))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("hash", func(b *testing.B) {
+		c := newCommitHasher(l)
+		n := 0
+		for b.Loop() {
+			c.advance(n, 1)
+			c.hash()
+			n++
+		}
+	})
+
+	for k := range 5 {
+		b.Run(fmt.Sprintf("word %d", k), func(b *testing.B) {
+			c := newCommitHasher(l)
+			n := 0
+			for b.Loop() {
+				c.advance(n, 1)
+				c.word(k)
+				n++
+			}
+		})
+	}
+}
+
+func TestTrimHeader(t *testing.T) {
+	for n, tc := range []struct {
+		head   []byte
+		header string
+		want   []byte
+	}{
+		{
+			head:   []byte{},
+			header: "f00",
+			want:   []byte{},
+		},
+		{
+			head: []byte(`tree 0000000000000000000000000000000000000000
+author Author Name <author@example.com> 1577872800 +0000
+committer Committer Name <committer@example.com> 1577876400 +0100
+f00 123`),
+			header: "f00",
+			want: []byte(`tree 0000000000000000000000000000000000000000
+author Author Name <author@example.com> 1577872800 +0000
+committer Committer Name <committer@example.com> 1577876400 +0100`),
+		},
+		{
+			head: []byte(`tree 0000000000000000000000000000000000000000
+author Author Name <author@example.com> 1577872800 +0000
+committer Committer Name <committer@example.com> 1577876400 +0100
+f00 123`),
+			header: "unknown",
+			want: []byte(`tree 0000000000000000000000000000000000000000
+author Author Name <author@example.com> 1577872800 +0000
+committer Committer Name <committer@example.com> 1577876400 +0100
+f00 123`),
+		},
+		{
+			head: []byte(`tree 0000000000000000000000000000000000000000
+author Author Name <author@example.com> 1577872800 +0000
+f00 123
+committer Committer Name <committer@example.com> 1577876400 +0100`),
+			header: "f00",
+			want: []byte(`tree 0000000000000000000000000000000000000000
+author Author Name <author@example.com> 1577872800 +0000
+f00 123
+committer Committer Name <committer@example.com> 1577876400 +0100`),
+		},
+	} {
+
+		got := trimHeader(tc.head, tc.header)
+
+		if !bytes.Equal(got, tc.want) {
+			t.Errorf("[%d] got:\n%s\n\nwant:\n%s", n, got, tc.want)
+		}
+	}
+}
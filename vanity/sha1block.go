@@ -0,0 +1,205 @@
+package vanity
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"math/bits"
+)
+
+// sha1Init is the SHA-1 state before any block has been hashed.
+var sha1Init = [5]uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476, 0xc3d2e1f0}
+
+const (
+	sha1K0 = 0x5a827999
+	sha1K1 = 0x6ed9eba1
+	sha1K2 = 0x8f1bbcdc
+	sha1K3 = 0xca62c1d6
+)
+
+// sha1WordRounds is the number of rounds after which each word of the state is
+// final. Each round shifts the words along, so the last word is settled four
+// rounds early, while the first one needs all 80.
+var sha1WordRounds = [5]int{80, 79, 78, 77, 76}
+
+// sha1Block returns the SHA-1 state h updated with the given block.
+func sha1Block(h [5]uint32, block *[sha1.BlockSize]byte) [5]uint32 {
+	a, b, c, d, e := sha1Rounds(h, block, 80)
+	return [5]uint32{h[0] + a, h[1] + b, h[2] + c, h[3] + d, h[4] + e}
+}
+
+// sha1BlockWord returns word k of the SHA-1 state h updated with the given
+// block, running only the rounds needed for it.
+func sha1BlockWord(h [5]uint32, block *[sha1.BlockSize]byte, k int) uint32 {
+	a, _, _, _, _ := sha1Rounds(h, block, sha1WordRounds[k])
+
+	// word k is the a of round sha1WordRounds[k], shifted along since; words
+	// 2 to 4 have been rotated on the way
+	if k >= 2 {
+		a = bits.RotateLeft32(a, 30)
+	}
+
+	return h[k] + a
+}
+
+// sha1Rounds runs the first n rounds of SHA-1 on the given block from state h,
+// returning the working variables. n must be at least 60.
+func sha1Rounds(h [5]uint32, block *[sha1.BlockSize]byte, n int) (a, b, c, d, e uint32) {
+	var w [16]uint32
+	for i := range w {
+		w[i] = binary.BigEndian.Uint32(block[i*4:])
+	}
+
+	return sha1RoundsFrom(h, &w, 0, 16, n)
+}
+
+// sha1RoundsFrom runs rounds from to n of SHA-1, from < 16 <= 60 <= n, given
+// the working variables v after the earlier rounds and the words w of the
+// block. It returns the working variables, using w for the message schedule.
+// The words of the schedule for rounds 16 to expanded, expanded at most 40,
+// are taken to be in w already, in place of the words they follow.
+func sha1RoundsFrom(v [5]uint32, w *[16]uint32, from, expanded, n int) (a, b, c, d, e uint32) {
+	a, b, c, d, e = v[0], v[1], v[2], v[3], v[4]
+
+	// from round 16 on, the schedule is kept in a ring of 16 words, each
+	// computed in place from earlier ones
+	i := from
+	for ; i < 16; i++ {
+		f := b&c | ^b&d
+		t := bits.RotateLeft32(a, 5) + f + e + w[i] + sha1K0
+		a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
+	}
+	for ; i < min(expanded, 20); i++ {
+		f := b&c | ^b&d
+		t := bits.RotateLeft32(a, 5) + f + e + w[i&0xf] + sha1K0
+		a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
+	}
+	for ; i < expanded; i++ {
+		f := b ^ c ^ d
+		t := bits.RotateLeft32(a, 5) + f + e + w[i&0xf] + sha1K1
+		a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
+	}
+	for ; i < 20; i++ {
+		x := w[(i-3)&0xf] ^ w[(i-8)&0xf] ^ w[(i-14)&0xf] ^ w[i&0xf]
+		w[i&0xf] = bits.RotateLeft32(x, 1)
+
+		f := b&c | ^b&d
+		t := bits.RotateLeft32(a, 5) + f + e + w[i&0xf] + sha1K0
+		a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
+	}
+	for ; i < 40; i++ {
+		x := w[(i-3)&0xf] ^ w[(i-8)&0xf] ^ w[(i-14)&0xf] ^ w[i&0xf]
+		w[i&0xf] = bits.RotateLeft32(x, 1)
+
+		f := b ^ c ^ d
+		t := bits.RotateLeft32(a, 5) + f + e + w[i&0xf] + sha1K1
+		a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
+	}
+	for ; i < 60; i++ {
+		x := w[(i-3)&0xf] ^ w[(i-8)&0xf] ^ w[(i-14)&0xf] ^ w[i&0xf]
+		w[i&0xf] = bits.RotateLeft32(x, 1)
+
+		f := (b|c)&d | b&c
+		t := bits.RotateLeft32(a, 5) + f + e + w[i&0xf] + sha1K2
+		a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
+	}
+	for ; i < n; i++ {
+		x := w[(i-3)&0xf] ^ w[(i-8)&0xf] ^ w[(i-14)&0xf] ^ w[i&0xf]
+		w[i&0xf] = bits.RotateLeft32(x, 1)
+
+		f := b ^ c ^ d
+		t := bits.RotateLeft32(a, 5) + f + e + w[i&0xf] + sha1K3
+		a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
+	}
+
+	return a, b, c, d, e
+}
+
+// sha1Partial hashes a block of which a single word changes between calls,
+// reusing the rounds before that word and the words of the message schedule
+// that do not depend on it.
+type sha1Partial struct {
+	h [5]uint32
+
+	// w are the words of the block, followed in place by those of the message
+	// schedule up to word expanded, and j the index of the one changing
+	w        [16]uint32
+	j        int
+	expanded int
+
+	// v are the working variables after the first j rounds
+	v [5]uint32
+}
+
+// reset prepares p for hashing the given block from state h, with word j of it
+// changing between calls.
+func (p *sha1Partial) reset(h [5]uint32, block *[sha1.BlockSize]byte, j int) {
+	p.h, p.j = h, j
+
+	for i := range p.w {
+		p.w[i] = binary.BigEndian.Uint32(block[i*4:])
+	}
+
+	a, b, c, d, e := h[0], h[1], h[2], h[3], h[4]
+
+	for i := range j {
+		f := b&c | ^b&d
+		t := bits.RotateLeft32(a, 5) + f + e + p.w[i] + sha1K0
+		a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
+	}
+
+	p.v = [5]uint32{a, b, c, d, e}
+
+	// word i of the schedule is made from words i-3, i-8, i-14 and i-16, so
+	// the first one made from word j is the first of j+3, j+8, j+14 and j+16
+	// past the block; those before it are expanded here, overwriting words
+	// of the block before j, which the later rounds no longer need
+	p.expanded = 16
+	for _, i := range []int{j + 3, j + 8, j + 14, j + 16} {
+		if i >= 16 {
+			p.expanded = i
+			break
+		}
+	}
+
+	for i := 16; i < p.expanded; i++ {
+		x := p.w[(i-3)&0xf] ^ p.w[(i-8)&0xf] ^ p.w[(i-14)&0xf] ^ p.w[i&0xf]
+		p.w[i&0xf] = bits.RotateLeft32(x, 1)
+	}
+}
+
+// block returns the state updated with the block, its word j set to v.
+func (p *sha1Partial) block(v uint32) [5]uint32 {
+	a, b, c, d, e := p.rounds(v, 80)
+	return [5]uint32{p.h[0] + a, p.h[1] + b, p.h[2] + c, p.h[3] + d, p.h[4] + e}
+}
+
+// word returns word k of the state updated with the block, its word j set to
+// v, running only the rounds needed for it.
+func (p *sha1Partial) word(v uint32, k int) uint32 {
+	a, _, _, _, _ := p.rounds(v, sha1WordRounds[k])
+
+	// see sha1BlockWord
+	if k >= 2 {
+		a = bits.RotateLeft32(a, 30)
+	}
+
+	return p.h[k] + a
+}
+
+// rounds runs rounds j to n of the block, its word j set to v, returning the
+// working variables.
+func (p *sha1Partial) rounds(v uint32, n int) (a, b, c, d, e uint32) {
+	w := p.w
+	w[p.j] = v
+	return sha1RoundsFrom(p.v, &w, p.j, p.expanded, n)
+}
+
+// sha1Blocks returns the SHA-1 state h updated with the given blocks. The
+// length of p must be a multiple of the block size.
+func sha1Blocks(h [5]uint32, p []byte) [5]uint32 {
+	for len(p) >= sha1.BlockSize {
+		h = sha1Block(h, (*[sha1.BlockSize]byte)(p))
+		p = p[sha1.BlockSize:]
+	}
+	return h
+}
@@ -0,0 +1,280 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// The state h and the message words w are word-major, each word a row of 16
+// lanes, of which the first 4 are hashed here, one in each lane of a V
+// register. The message words are overwritten with the message schedule.
+//
+// V0 to V4 hold the working variables, rotating roles each round, V10 the
+// message word, V6, V7 and V9 temporaries and V8 the round constant. R0 and
+// R1 point to h and w, and R2 to the message word last loaded.
+
+// LOAD loads the message word at offset o into V10.
+#define LOAD(o) \
+	ADD  $o, R1, R2; \
+	VLD1 (R2), [V10.S4]
+
+// SCHED computes the message schedule word at offset o0 from the earlier
+// words at o3, o8 and o14 and the one it replaces, into V10 and back to o0.
+#define SCHED(o0, o3, o8, o14) \
+	ADD   $o3, R1, R2; \
+	VLD1  (R2), [V9.S4]; \
+	ADD   $o8, R1, R2; \
+	VLD1  (R2), [V6.S4]; \
+	VEOR  V6.B16, V9.B16, V9.B16; \
+	ADD   $o14, R1, R2; \
+	VLD1  (R2), [V6.S4]; \
+	VEOR  V6.B16, V9.B16, V9.B16; \
+	ADD   $o0, R1, R2; \
+	VLD1  (R2), [V6.S4]; \
+	VEOR  V6.B16, V9.B16, V9.B16; \
+	VSHL  $1, V9.S4, V10.S4; \
+	VSRI  $31, V9.S4, V10.S4; \
+	VST1  [V10.S4], (R2)
+
+// CH, PARITY and MAJ compute the round functions of b, c and d into V6.
+#define CH(b, c, d) \
+	VEOR c.B16, d.B16, V6.B16; \
+	VAND b.B16, V6.B16, V6.B16; \
+	VEOR d.B16, V6.B16, V6.B16
+
+#define PARITY(b, c, d) \
+	VEOR b.B16, c.B16, V6.B16; \
+	VEOR d.B16, V6.B16, V6.B16
+
+#define MAJ(b, c, d) \
+	VORR b.B16, c.B16, V6.B16; \
+	VAND d.B16, V6.B16, V6.B16; \
+	VAND b.B16, c.B16, V7.B16; \
+	VORR V7.B16, V6.B16, V6.B16
+
+// MIX adds the round function in V6, the message word in V10, the round
+// constant in V8 and a rotated left by 5 to e, and rotates b left by 30.
+#define MIX(a, b, e) \
+	VADD V6.S4, e.S4, e.S4; \
+	VADD V10.S4, e.S4, e.S4; \
+	VADD V8.S4, e.S4, e.S4; \
+	VSHL $5, a.S4, V6.S4; \
+	VSRI $27, a.S4, V6.S4; \
+	VADD V6.S4, e.S4, e.S4; \
+	VSHL $30, b.S4, V6.S4; \
+	VSRI $2, b.S4, V6.S4; \
+	VORR V6.B16, V6.B16, b.B16
+
+#define ROUND1(a, b, c, d, e) CH(b, c, d); MIX(a, b, e)
+#define ROUND2(a, b, c, d, e) PARITY(b, c, d); MIX(a, b, e)
+#define ROUND3(a, b, c, d, e) MAJ(b, c, d); MIX(a, b, e)
+#define ROUND4(a, b, c, d, e) PARITY(b, c, d); MIX(a, b, e)
+
+// K broadcasts the round constant k to V8.
+#define K(k) \
+	MOVW $k, R3; \
+	VDUP R3, V8.S4
+
+// func sha1BlockNEON(h *[5][maxLanes]uint32, w *[16][maxLanes]uint32)
+TEXT ·sha1BlockNEON(SB), NOSPLIT, $0-16
+	MOVD h+0(FP), R0
+	MOVD w+8(FP), R1
+
+	ADD  $0, R0, R2
+	VLD1 (R2), [V0.S4]
+	ADD  $64, R0, R2
+	VLD1 (R2), [V1.S4]
+	ADD  $128, R0, R2
+	VLD1 (R2), [V2.S4]
+	ADD  $192, R0, R2
+	VLD1 (R2), [V3.S4]
+	ADD  $256, R0, R2
+	VLD1 (R2), [V4.S4]
+
+	K(0x5a827999)
+	LOAD(0)
+	ROUND1(V0, V1, V2, V3, V4)
+	LOAD(64)
+	ROUND1(V4, V0, V1, V2, V3)
+	LOAD(128)
+	ROUND1(V3, V4, V0, V1, V2)
+	LOAD(192)
+	ROUND1(V2, V3, V4, V0, V1)
+	LOAD(256)
+	ROUND1(V1, V2, V3, V4, V0)
+	LOAD(320)
+	ROUND1(V0, V1, V2, V3, V4)
+	LOAD(384)
+	ROUND1(V4, V0, V1, V2, V3)
+	LOAD(448)
+	ROUND1(V3, V4, V0, V1, V2)
+	LOAD(512)
+	ROUND1(V2, V3, V4, V0, V1)
+	LOAD(576)
+	ROUND1(V1, V2, V3, V4, V0)
+	LOAD(640)
+	ROUND1(V0, V1, V2, V3, V4)
+	LOAD(704)
+	ROUND1(V4, V0, V1, V2, V3)
+	LOAD(768)
+	ROUND1(V3, V4, V0, V1, V2)
+	LOAD(832)
+	ROUND1(V2, V3, V4, V0, V1)
+	LOAD(896)
+	ROUND1(V1, V2, V3, V4, V0)
+	LOAD(960)
+	ROUND1(V0, V1, V2, V3, V4)
+	SCHED(0, 832, 512, 128)
+	ROUND1(V4, V0, V1, V2, V3)
+	SCHED(64, 896, 576, 192)
+	ROUND1(V3, V4, V0, V1, V2)
+	SCHED(128, 960, 640, 256)
+	ROUND1(V2, V3, V4, V0, V1)
+	SCHED(192, 0, 704, 320)
+	ROUND1(V1, V2, V3, V4, V0)
+
+	K(0x6ed9eba1)
+	SCHED(256, 64, 768, 384)
+	ROUND2(V0, V1, V2, V3, V4)
+	SCHED(320, 128, 832, 448)
+	ROUND2(V4, V0, V1, V2, V3)
+	SCHED(384, 192, 896, 512)
+	ROUND2(V3, V4, V0, V1, V2)
+	SCHED(448, 256, 960, 576)
+	ROUND2(V2, V3, V4, V0, V1)
+	SCHED(512, 320, 0, 640)
+	ROUND2(V1, V2, V3, V4, V0)
+	SCHED(576, 384, 64, 704)
+	ROUND2(V0, V1, V2, V3, V4)
+	SCHED(640, 448, 128, 768)
+	ROUND2(V4, V0, V1, V2, V3)
+	SCHED(704, 512, 192, 832)
+	ROUND2(V3, V4, V0, V1, V2)
+	SCHED(768, 576, 256, 896)
+	ROUND2(V2, V3, V4, V0, V1)
+	SCHED(832, 640, 320, 960)
+	ROUND2(V1, V2, V3, V4, V0)
+	SCHED(896, 704, 384, 0)
+	ROUND2(V0, V1, V2, V3, V4)
+	SCHED(960, 768, 448, 64)
+	ROUND2(V4, V0, V1, V2, V3)
+	SCHED(0, 832, 512, 128)
+	ROUND2(V3, V4, V0, V1, V2)
+	SCHED(64, 896, 576, 192)
+	ROUND2(V2, V3, V4, V0, V1)
+	SCHED(128, 960, 640, 256)
+	ROUND2(V1, V2, V3, V4, V0)
+	SCHED(192, 0, 704, 320)
+	ROUND2(V0, V1, V2, V3, V4)
+	SCHED(256, 64, 768, 384)
+	ROUND2(V4, V0, V1, V2, V3)
+	SCHED(320, 128, 832, 448)
+	ROUND2(V3, V4, V0, V1, V2)
+	SCHED(384, 192, 896, 512)
+	ROUND2(V2, V3, V4, V0, V1)
+	SCHED(448, 256, 960, 576)
+	ROUND2(V1, V2, V3, V4, V0)
+
+	K(0x8f1bbcdc)
+	SCHED(512, 320, 0, 640)
+	ROUND3(V0, V1, V2, V3, V4)
+	SCHED(576, 384, 64, 704)
+	ROUND3(V4, V0, V1, V2, V3)
+	SCHED(640, 448, 128, 768)
+	ROUND3(V3, V4, V0, V1, V2)
+	SCHED(704, 512, 192, 832)
+	ROUND3(V2, V3, V4, V0, V1)
+	SCHED(768, 576, 256, 896)
+	ROUND3(V1, V2, V3, V4, V0)
+	SCHED(832, 640, 320, 960)
+	ROUND3(V0, V1, V2, V3, V4)
+	SCHED(896, 704, 384, 0)
+	ROUND3(V4, V0, V1, V2, V3)
+	SCHED(960, 768, 448, 64)
+	ROUND3(V3, V4, V0, V1, V2)
+	SCHED(0, 832, 512, 128)
+	ROUND3(V2, V3, V4, V0, V1)
+	SCHED(64, 896, 576, 192)
+	ROUND3(V1, V2, V3, V4, V0)
+	SCHED(128, 960, 640, 256)
+	ROUND3(V0, V1, V2, V3, V4)
+	SCHED(192, 0, 704, 320)
+	ROUND3(V4, V0, V1, V2, V3)
+	SCHED(256, 64, 768, 384)
+	ROUND3(V3, V4, V0, V1, V2)
+	SCHED(320, 128, 832, 448)
+	ROUND3(V2, V3, V4, V0, V1)
+	SCHED(384, 192, 896, 512)
+	ROUND3(V1, V2, V3, V4, V0)
+	SCHED(448, 256, 960, 576)
+	ROUND3(V0, V1, V2, V3, V4)
+	SCHED(512, 320, 0, 640)
+	ROUND3(V4, V0, V1, V2, V3)
+	SCHED(576, 384, 64, 704)
+	ROUND3(V3, V4, V0, V1, V2)
+	SCHED(640, 448, 128, 768)
+	ROUND3(V2, V3, V4, V0, V1)
+	SCHED(704, 512, 192, 832)
+	ROUND3(V1, V2, V3, V4, V0)
+
+	K(0xca62c1d6)
+	SCHED(768, 576, 256, 896)
+	ROUND4(V0, V1, V2, V3, V4)
+	SCHED(832, 640, 320, 960)
+	ROUND4(V4, V0, V1, V2, V3)
+	SCHED(896, 704, 384, 0)
+	ROUND4(V3, V4, V0, V1, V2)
+	SCHED(960, 768, 448, 64)
+	ROUND4(V2, V3, V4, V0, V1)
+	SCHED(0, 832, 512, 128)
+	ROUND4(V1, V2, V3, V4, V0)
+	SCHED(64, 896, 576, 192)
+	ROUND4(V0, V1, V2, V3, V4)
+	SCHED(128, 960, 640, 256)
+	ROUND4(V4, V0, V1, V2, V3)
+	SCHED(192, 0, 704, 320)
+	ROUND4(V3, V4, V0, V1, V2)
+	SCHED(256, 64, 768, 384)
+	ROUND4(V2, V3, V4, V0, V1)
+	SCHED(320, 128, 832, 448)
+	ROUND4(V1, V2, V3, V4, V0)
+	SCHED(384, 192, 896, 512)
+	ROUND4(V0, V1, V2, V3, V4)
+	SCHED(448, 256, 960, 576)
+	ROUND4(V4, V0, V1, V2, V3)
+	SCHED(512, 320, 0, 640)
+	ROUND4(V3, V4, V0, V1, V2)
+	SCHED(576, 384, 64, 704)
+	ROUND4(V2, V3, V4, V0, V1)
+	SCHED(640, 448, 128, 768)
+	ROUND4(V1, V2, V3, V4, V0)
+	SCHED(704, 512, 192, 832)
+	ROUND4(V0, V1, V2, V3, V4)
+	SCHED(768, 576, 256, 896)
+	ROUND4(V4, V0, V1, V2, V3)
+	SCHED(832, 640, 320, 960)
+	ROUND4(V3, V4, V0, V1, V2)
+	SCHED(896, 704, 384, 0)
+	ROUND4(V2, V3, V4, V0, V1)
+	SCHED(960, 768, 448, 64)
+	ROUND4(V1, V2, V3, V4, V0)
+
+	ADD  $0, R0, R2
+	VLD1 (R2), [V6.S4]
+	VADD V6.S4, V0.S4, V0.S4
+	VST1 [V0.S4], (R2)
+	ADD  $64, R0, R2
+	VLD1 (R2), [V6.S4]
+	VADD V6.S4, V1.S4, V1.S4
+	VST1 [V1.S4], (R2)
+	ADD  $128, R0, R2
+	VLD1 (R2), [V6.S4]
+	VADD V6.S4, V2.S4, V2.S4
+	VST1 [V2.S4], (R2)
+	ADD  $192, R0, R2
+	VLD1 (R2), [V6.S4]
+	VADD V6.S4, V3.S4, V3.S4
+	VST1 [V3.S4], (R2)
+	ADD  $256, R0, R2
+	VLD1 (R2), [V6.S4]
+	VADD V6.S4, V4.S4, V4.S4
+	VST1 [V4.S4], (R2)
+
+	RET
@@ -0,0 +1,260 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// The state h and the message words w are word-major, each word a row of 16
+// lanes, of which the first 8 are hashed here, one in each lane of a Y
+// register. The message words are overwritten with the message schedule.
+//
+// Y0 to Y4 hold the working variables, rotating roles each round, Y5 the
+// message word, Y6 and Y7 temporaries and Y8 the round constant.
+
+// LOAD loads the message word at offset o into Y5.
+#define LOAD(o) \
+	VMOVDQU o(DI), Y5
+
+// SCHED computes the message schedule word at offset o0 from the earlier
+// words at o3, o8 and o14 and the one it replaces, into Y5 and back to o0.
+#define SCHED(o0, o3, o8, o14) \
+	VMOVDQU o3(DI), Y5; \
+	VPXOR   o8(DI), Y5, Y5; \
+	VPXOR   o14(DI), Y5, Y5; \
+	VPXOR   o0(DI), Y5, Y5; \
+	VPSLLD  $1, Y5, Y6; \
+	VPSRLD  $31, Y5, Y5; \
+	VPOR    Y6, Y5, Y5; \
+	VMOVDQU Y5, o0(DI)
+
+// CH, PARITY and MAJ compute the round functions of b, c and d into Y6.
+#define CH(b, c, d) \
+	VPXOR c, d, Y6; \
+	VPAND b, Y6, Y6; \
+	VPXOR d, Y6, Y6
+
+#define PARITY(b, c, d) \
+	VPXOR b, c, Y6; \
+	VPXOR d, Y6, Y6
+
+#define MAJ(b, c, d) \
+	VPOR  b, c, Y6; \
+	VPAND d, Y6, Y6; \
+	VPAND b, c, Y7; \
+	VPOR  Y7, Y6, Y6
+
+// MIX adds the round function in Y6, the message word in Y5, the round
+// constant in Y8 and a rotated left by 5 to e, and rotates b left by 30.
+#define MIX(a, b, e) \
+	VPADDD Y6, e, e; \
+	VPADDD Y5, e, e; \
+	VPADDD Y8, e, e; \
+	VPSLLD $5, a, Y6; \
+	VPSRLD $27, a, Y7; \
+	VPOR   Y7, Y6, Y6; \
+	VPADDD Y6, e, e; \
+	VPSLLD $30, b, Y6; \
+	VPSRLD $2, b, b; \
+	VPOR   Y6, b, b
+
+#define ROUND1(a, b, c, d, e) CH(b, c, d); MIX(a, b, e)
+#define ROUND2(a, b, c, d, e) PARITY(b, c, d); MIX(a, b, e)
+#define ROUND3(a, b, c, d, e) MAJ(b, c, d); MIX(a, b, e)
+#define ROUND4(a, b, c, d, e) PARITY(b, c, d); MIX(a, b, e)
+
+// K broadcasts the round constant k to Y8.
+#define K(k) \
+	MOVL         $k, AX; \
+	VMOVD        AX, X8; \
+	VPBROADCASTD X8, Y8
+
+// func sha1BlockAVX2(h *[5][maxLanes]uint32, w *[16][maxLanes]uint32)
+TEXT ·sha1BlockAVX2(SB), NOSPLIT, $0-16
+	MOVQ h+0(FP), SI
+	MOVQ w+8(FP), DI
+
+	VMOVDQU 0(SI), Y0
+	VMOVDQU 64(SI), Y1
+	VMOVDQU 128(SI), Y2
+	VMOVDQU 192(SI), Y3
+	VMOVDQU 256(SI), Y4
+
+	K(0x5a827999)
+	LOAD(0)
+	ROUND1(Y0, Y1, Y2, Y3, Y4)
+	LOAD(64)
+	ROUND1(Y4, Y0, Y1, Y2, Y3)
+	LOAD(128)
+	ROUND1(Y3, Y4, Y0, Y1, Y2)
+	LOAD(192)
+	ROUND1(Y2, Y3, Y4, Y0, Y1)
+	LOAD(256)
+	ROUND1(Y1, Y2, Y3, Y4, Y0)
+	LOAD(320)
+	ROUND1(Y0, Y1, Y2, Y3, Y4)
+	LOAD(384)
+	ROUND1(Y4, Y0, Y1, Y2, Y3)
+	LOAD(448)
+	ROUND1(Y3, Y4, Y0, Y1, Y2)
+	LOAD(512)
+	ROUND1(Y2, Y3, Y4, Y0, Y1)
+	LOAD(576)
+	ROUND1(Y1, Y2, Y3, Y4, Y0)
+	LOAD(640)
+	ROUND1(Y0, Y1, Y2, Y3, Y4)
+	LOAD(704)
+	ROUND1(Y4, Y0, Y1, Y2, Y3)
+	LOAD(768)
+	ROUND1(Y3, Y4, Y0, Y1, Y2)
+	LOAD(832)
+	ROUND1(Y2, Y3, Y4, Y0, Y1)
+	LOAD(896)
+	ROUND1(Y1, Y2, Y3, Y4, Y0)
+	LOAD(960)
+	ROUND1(Y0, Y1, Y2, Y3, Y4)
+	SCHED(0, 832, 512, 128)
+	ROUND1(Y4, Y0, Y1, Y2, Y3)
+	SCHED(64, 896, 576, 192)
+	ROUND1(Y3, Y4, Y0, Y1, Y2)
+	SCHED(128, 960, 640, 256)
+	ROUND1(Y2, Y3, Y4, Y0, Y1)
+	SCHED(192, 0, 704, 320)
+	ROUND1(Y1, Y2, Y3, Y4, Y0)
+
+	K(0x6ed9eba1)
+	SCHED(256, 64, 768, 384)
+	ROUND2(Y0, Y1, Y2, Y3, Y4)
+	SCHED(320, 128, 832, 448)
+	ROUND2(Y4, Y0, Y1, Y2, Y3)
+	SCHED(384, 192, 896, 512)
+	ROUND2(Y3, Y4, Y0, Y1, Y2)
+	SCHED(448, 256, 960, 576)
+	ROUND2(Y2, Y3, Y4, Y0, Y1)
+	SCHED(512, 320, 0, 640)
+	ROUND2(Y1, Y2, Y3, Y4, Y0)
+	SCHED(576, 384, 64, 704)
+	ROUND2(Y0, Y1, Y2, Y3, Y4)
+	SCHED(640, 448, 128, 768)
+	ROUND2(Y4, Y0, Y1, Y2, Y3)
+	SCHED(704, 512, 192, 832)
+	ROUND2(Y3, Y4, Y0, Y1, Y2)
+	SCHED(768, 576, 256, 896)
+	ROUND2(Y2, Y3, Y4, Y0, Y1)
+	SCHED(832, 640, 320, 960)
+	ROUND2(Y1, Y2, Y3, Y4, Y0)
+	SCHED(896, 704, 384, 0)
+	ROUND2(Y0, Y1, Y2, Y3, Y4)
+	SCHED(960, 768, 448, 64)
+	ROUND2(Y4, Y0, Y1, Y2, Y3)
+	SCHED(0, 832, 512, 128)
+	ROUND2(Y3, Y4, Y0, Y1, Y2)
+	SCHED(64, 896, 576, 192)
+	ROUND2(Y2, Y3, Y4, Y0, Y1)
+	SCHED(128, 960, 640, 256)
+	ROUND2(Y1, Y2, Y3, Y4, Y0)
+	SCHED(192, 0, 704, 320)
+	ROUND2(Y0, Y1, Y2, Y3, Y4)
+	SCHED(256, 64, 768, 384)
+	ROUND2(Y4, Y0, Y1, Y2, Y3)
+	SCHED(320, 128, 832, 448)
+	ROUND2(Y3, Y4, Y0, Y1, Y2)
+	SCHED(384, 192, 896, 512)
+	ROUND2(Y2, Y3, Y4, Y0, Y1)
+	SCHED(448, 256, 960, 576)
+	ROUND2(Y1, Y2, Y3, Y4, Y0)
+
+	K(0x8f1bbcdc)
+	SCHED(512, 320, 0, 640)
+	ROUND3(Y0, Y1, Y2, Y3, Y4)
+	SCHED(576, 384, 64, 704)
+	ROUND3(Y4, Y0, Y1, Y2, Y3)
+	SCHED(640, 448, 128, 768)
+	ROUND3(Y3, Y4, Y0, Y1, Y2)
+	SCHED(704, 512, 192, 832)
+	ROUND3(Y2, Y3, Y4, Y0, Y1)
+	SCHED(768, 576, 256, 896)
+	ROUND3(Y1, Y2, Y3, Y4, Y0)
+	SCHED(832, 640, 320, 960)
+	ROUND3(Y0, Y1, Y2, Y3, Y4)
+	SCHED(896, 704, 384, 0)
+	ROUND3(Y4, Y0, Y1, Y2, Y3)
+	SCHED(960, 768, 448, 64)
+	ROUND3(Y3, Y4, Y0, Y1, Y2)
+	SCHED(0, 832, 512, 128)
+	ROUND3(Y2, Y3, Y4, Y0, Y1)
+	SCHED(64, 896, 576, 192)
+	ROUND3(Y1, Y2, Y3, Y4, Y0)
+	SCHED(128, 960, 640, 256)
+	ROUND3(Y0, Y1, Y2, Y3, Y4)
+	SCHED(192, 0, 704, 320)
+	ROUND3(Y4, Y0, Y1, Y2, Y3)
+	SCHED(256, 64, 768, 384)
+	ROUND3(Y3, Y4, Y0, Y1, Y2)
+	SCHED(320, 128, 832, 448)
+	ROUND3(Y2, Y3, Y4, Y0, Y1)
+	SCHED(384, 192, 896, 512)
+	ROUND3(Y1, Y2, Y3, Y4, Y0)
+	SCHED(448, 256, 960, 576)
+	ROUND3(Y0, Y1, Y2, Y3, Y4)
+	SCHED(512, 320, 0, 640)
+	ROUND3(Y4, Y0, Y1, Y2, Y3)
+	SCHED(576, 384, 64, 704)
+	ROUND3(Y3, Y4, Y0, Y1, Y2)
+	SCHED(640, 448, 128, 768)
+	ROUND3(Y2, Y3, Y4, Y0, Y1)
+	SCHED(704, 512, 192, 832)
+	ROUND3(Y1, Y2, Y3, Y4, Y0)
+
+	K(0xca62c1d6)
+	SCHED(768, 576, 256, 896)
+	ROUND4(Y0, Y1, Y2, Y3, Y4)
+	SCHED(832, 640, 320, 960)
+	ROUND4(Y4, Y0, Y1, Y2, Y3)
+	SCHED(896, 704, 384, 0)
+	ROUND4(Y3, Y4, Y0, Y1, Y2)
+	SCHED(960, 768, 448, 64)
+	ROUND4(Y2, Y3, Y4, Y0, Y1)
+	SCHED(0, 832, 512, 128)
+	ROUND4(Y1, Y2, Y3, Y4, Y0)
+	SCHED(64, 896, 576, 192)
+	ROUND4(Y0, Y1, Y2, Y3, Y4)
+	SCHED(128, 960, 640, 256)
+	ROUND4(Y4, Y0, Y1, Y2, Y3)
+	SCHED(192, 0, 704, 320)
+	ROUND4(Y3, Y4, Y0, Y1, Y2)
+	SCHED(256, 64, 768, 384)
+	ROUND4(Y2, Y3, Y4, Y0, Y1)
+	SCHED(320, 128, 832, 448)
+	ROUND4(Y1, Y2, Y3, Y4, Y0)
+	SCHED(384, 192, 896, 512)
+	ROUND4(Y0, Y1, Y2, Y3, Y4)
+	SCHED(448, 256, 960, 576)
+	ROUND4(Y4, Y0, Y1, Y2, Y3)
+	SCHED(512, 320, 0, 640)
+	ROUND4(Y3, Y4, Y0, Y1, Y2)
+	SCHED(576, 384, 64, 704)
+	ROUND4(Y2, Y3, Y4, Y0, Y1)
+	SCHED(640, 448, 128, 768)
+	ROUND4(Y1, Y2, Y3, Y4, Y0)
+	SCHED(704, 512, 192, 832)
+	ROUND4(Y0, Y1, Y2, Y3, Y4)
+	SCHED(768, 576, 256, 896)
+	ROUND4(Y4, Y0, Y1, Y2, Y3)
+	SCHED(832, 640, 320, 960)
+	ROUND4(Y3, Y4, Y0, Y1, Y2)
+	SCHED(896, 704, 384, 0)
+	ROUND4(Y2, Y3, Y4, Y0, Y1)
+	SCHED(960, 768, 448, 64)
+	ROUND4(Y1, Y2, Y3, Y4, Y0)
+
+	VPADDD  0(SI), Y0, Y0
+	VMOVDQU Y0, 0(SI)
+	VPADDD  64(SI), Y1, Y1
+	VMOVDQU Y1, 64(SI)
+	VPADDD  128(SI), Y2, Y2
+	VMOVDQU Y2, 128(SI)
+	VPADDD  192(SI), Y3, Y3
+	VMOVDQU Y3, 192(SI)
+	VPADDD  256(SI), Y4, Y4
+	VMOVDQU Y4, 256(SI)
+
+	VZEROUPPER
+	RET
@@ -0,0 +1,251 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// The state h and the message words w are word-major, each word a row of 16
+// lanes, all hashed here, one in each lane of a Z register. The message words
+// are overwritten with the message schedule.
+//
+// Z0 to Z4 hold the working variables, rotating roles each round, Z5 the
+// message word, Z6 a temporary and Z8 the round constant.
+
+// LOAD loads the message word at offset o into Z5.
+#define LOAD(o) \
+	VMOVDQU32 o(DI), Z5
+
+// SCHED computes the message schedule word at offset o0 from the earlier
+// words at o3, o8 and o14 and the one it replaces, into Z5 and back to o0.
+#define SCHED(o0, o3, o8, o14) \
+	VMOVDQU32 o3(DI), Z5; \
+	VPXORD    o8(DI), Z5, Z5; \
+	VPXORD    o14(DI), Z5, Z5; \
+	VPXORD    o0(DI), Z5, Z5; \
+	VPROLD    $1, Z5, Z5; \
+	VMOVDQU32 Z5, o0(DI)
+
+// CH, PARITY and MAJ compute the round functions of b, c and d into Z6, as
+// ternary logic on b, c and d.
+#define CH(b, c, d) \
+	VMOVDQA32  b, Z6; \
+	VPTERNLOGD $0xca, d, c, Z6
+
+#define PARITY(b, c, d) \
+	VMOVDQA32  b, Z6; \
+	VPTERNLOGD $0x96, d, c, Z6
+
+#define MAJ(b, c, d) \
+	VMOVDQA32  b, Z6; \
+	VPTERNLOGD $0xe8, d, c, Z6
+
+// MIX adds the round function in Z6, the message word in Z5, the round
+// constant in Z8 and a rotated left by 5 to e, and rotates b left by 30.
+#define MIX(a, b, e) \
+	VPADDD Z6, e, e; \
+	VPADDD Z5, e, e; \
+	VPADDD Z8, e, e; \
+	VPROLD $5, a, Z6; \
+	VPADDD Z6, e, e; \
+	VPROLD $30, b, b
+
+#define ROUND1(a, b, c, d, e) CH(b, c, d); MIX(a, b, e)
+#define ROUND2(a, b, c, d, e) PARITY(b, c, d); MIX(a, b, e)
+#define ROUND3(a, b, c, d, e) MAJ(b, c, d); MIX(a, b, e)
+#define ROUND4(a, b, c, d, e) PARITY(b, c, d); MIX(a, b, e)
+
+// K broadcasts the round constant k to Z8.
+#define K(k) \
+	MOVL         $k, AX; \
+	VPBROADCASTD AX, Z8
+
+// func sha1BlockAVX512(h *[5][maxLanes]uint32, w *[16][maxLanes]uint32)
+TEXT ·sha1BlockAVX512(SB), NOSPLIT, $0-16
+	MOVQ h+0(FP), SI
+	MOVQ w+8(FP), DI
+
+	VMOVDQU32 0(SI), Z0
+	VMOVDQU32 64(SI), Z1
+	VMOVDQU32 128(SI), Z2
+	VMOVDQU32 192(SI), Z3
+	VMOVDQU32 256(SI), Z4
+
+	K(0x5a827999)
+	LOAD(0)
+	ROUND1(Z0, Z1, Z2, Z3, Z4)
+	LOAD(64)
+	ROUND1(Z4, Z0, Z1, Z2, Z3)
+	LOAD(128)
+	ROUND1(Z3, Z4, Z0, Z1, Z2)
+	LOAD(192)
+	ROUND1(Z2, Z3, Z4, Z0, Z1)
+	LOAD(256)
+	ROUND1(Z1, Z2, Z3, Z4, Z0)
+	LOAD(320)
+	ROUND1(Z0, Z1, Z2, Z3, Z4)
+	LOAD(384)
+	ROUND1(Z4, Z0, Z1, Z2, Z3)
+	LOAD(448)
+	ROUND1(Z3, Z4, Z0, Z1, Z2)
+	LOAD(512)
+	ROUND1(Z2, Z3, Z4, Z0, Z1)
+	LOAD(576)
+	ROUND1(Z1, Z2, Z3, Z4, Z0)
+	LOAD(640)
+	ROUND1(Z0, Z1, Z2, Z3, Z4)
+	LOAD(704)
+	ROUND1(Z4, Z0, Z1, Z2, Z3)
+	LOAD(768)
+	ROUND1(Z3, Z4, Z0, Z1, Z2)
+	LOAD(832)
+	ROUND1(Z2, Z3, Z4, Z0, Z1)
+	LOAD(896)
+	ROUND1(Z1, Z2, Z3, Z4, Z0)
+	LOAD(960)
+	ROUND1(Z0, Z1, Z2, Z3, Z4)
+	SCHED(0, 832, 512, 128)
+	ROUND1(Z4, Z0, Z1, Z2, Z3)
+	SCHED(64, 896, 576, 192)
+	ROUND1(Z3, Z4, Z0, Z1, Z2)
+	SCHED(128, 960, 640, 256)
+	ROUND1(Z2, Z3, Z4, Z0, Z1)
+	SCHED(192, 0, 704, 320)
+	ROUND1(Z1, Z2, Z3, Z4, Z0)
+
+	K(0x6ed9eba1)
+	SCHED(256, 64, 768, 384)
+	ROUND2(Z0, Z1, Z2, Z3, Z4)
+	SCHED(320, 128, 832, 448)
+	ROUND2(Z4, Z0, Z1, Z2, Z3)
+	SCHED(384, 192, 896, 512)
+	ROUND2(Z3, Z4, Z0, Z1, Z2)
+	SCHED(448, 256, 960, 576)
+	ROUND2(Z2, Z3, Z4, Z0, Z1)
+	SCHED(512, 320, 0, 640)
+	ROUND2(Z1, Z2, Z3, Z4, Z0)
+	SCHED(576, 384, 64, 704)
+	ROUND2(Z0, Z1, Z2, Z3, Z4)
+	SCHED(640, 448, 128, 768)
+	ROUND2(Z4, Z0, Z1, Z2, Z3)
+	SCHED(704, 512, 192, 832)
+	ROUND2(Z3, Z4, Z0, Z1, Z2)
+	SCHED(768, 576, 256, 896)
+	ROUND2(Z2, Z3, Z4, Z0, Z1)
+	SCHED(832, 640, 320, 960)
+	ROUND2(Z1, Z2, Z3, Z4, Z0)
+	SCHED(896, 704, 384, 0)
+	ROUND2(Z0, Z1, Z2, Z3, Z4)
+	SCHED(960, 768, 448, 64)
+	ROUND2(Z4, Z0, Z1, Z2, Z3)
+	SCHED(0, 832, 512, 128)
+	ROUND2(Z3, Z4, Z0, Z1, Z2)
+	SCHED(64, 896, 576, 192)
+	ROUND2(Z2, Z3, Z4, Z0, Z1)
+	SCHED(128, 960, 640, 256)
+	ROUND2(Z1, Z2, Z3, Z4, Z0)
+	SCHED(192, 0, 704, 320)
+	ROUND2(Z0, Z1, Z2, Z3, Z4)
+	SCHED(256, 64, 768, 384)
+	ROUND2(Z4, Z0, Z1, Z2, Z3)
+	SCHED(320, 128, 832, 448)
+	ROUND2(Z3, Z4, Z0, Z1, Z2)
+	SCHED(384, 192, 896, 512)
+	ROUND2(Z2, Z3, Z4, Z0, Z1)
+	SCHED(448, 256, 960, 576)
+	ROUND2(Z1, Z2, Z3, Z4, Z0)
+
+	K(0x8f1bbcdc)
+	SCHED(512, 320, 0, 640)
+	ROUND3(Z0, Z1, Z2, Z3, Z4)
+	SCHED(576, 384, 64, 704)
+	ROUND3(Z4, Z0, Z1, Z2, Z3)
+	SCHED(640, 448, 128, 768)
+	ROUND3(Z3, Z4, Z0, Z1, Z2)
+	SCHED(704, 512, 192, 832)
+	ROUND3(Z2, Z3, Z4, Z0, Z1)
+	SCHED(768, 576, 256, 896)
+	ROUND3(Z1, Z2, Z3, Z4, Z0)
+	SCHED(832, 640, 320, 960)
+	ROUND3(Z0, Z1, Z2, Z3, Z4)
+	SCHED(896, 704, 384, 0)
+	ROUND3(Z4, Z0, Z1, Z2, Z3)
+	SCHED(960, 768, 448, 64)
+	ROUND3(Z3, Z4, Z0, Z1, Z2)
+	SCHED(0, 832, 512, 128)
+	ROUND3(Z2, Z3, Z4, Z0, Z1)
+	SCHED(64, 896, 576, 192)
+	ROUND3(Z1, Z2, Z3, Z4, Z0)
+	SCHED(128, 960, 640, 256)
+	ROUND3(Z0, Z1, Z2, Z3, Z4)
+	SCHED(192, 0, 704, 320)
+	ROUND3(Z4, Z0, Z1, Z2, Z3)
+	SCHED(256, 64, 768, 384)
+	ROUND3(Z3, Z4, Z0, Z1, Z2)
+	SCHED(320, 128, 832, 448)
+	ROUND3(Z2, Z3, Z4, Z0, Z1)
+	SCHED(384, 192, 896, 512)
+	ROUND3(Z1, Z2, Z3, Z4, Z0)
+	SCHED(448, 256, 960, 576)
+	ROUND3(Z0, Z1, Z2, Z3, Z4)
+	SCHED(512, 320, 0, 640)
+	ROUND3(Z4, Z0, Z1, Z2, Z3)
+	SCHED(576, 384, 64, 704)
+	ROUND3(Z3, Z4, Z0, Z1, Z2)
+	SCHED(640, 448, 128, 768)
+	ROUND3(Z2, Z3, Z4, Z0, Z1)
+	SCHED(704, 512, 192, 832)
+	ROUND3(Z1, Z2, Z3, Z4, Z0)
+
+	K(0xca62c1d6)
+	SCHED(768, 576, 256, 896)
+	ROUND4(Z0, Z1, Z2, Z3, Z4)
+	SCHED(832, 640, 320, 960)
+	ROUND4(Z4, Z0, Z1, Z2, Z3)
+	SCHED(896, 704, 384, 0)
+	ROUND4(Z3, Z4, Z0, Z1, Z2)
+	SCHED(960, 768, 448, 64)
+	ROUND4(Z2, Z3, Z4, Z0, Z1)
+	SCHED(0, 832, 512, 128)
+	ROUND4(Z1, Z2, Z3, Z4, Z0)
+	SCHED(64, 896, 576, 192)
+	ROUND4(Z0, Z1, Z2, Z3, Z4)
+	SCHED(128, 960, 640, 256)
+	ROUND4(Z4, Z0, Z1, Z2, Z3)
+	SCHED(192, 0, 704, 320)
+	ROUND4(Z3, Z4, Z0, Z1, Z2)
+	SCHED(256, 64, 768, 384)
+	ROUND4(Z2, Z3, Z4, Z0, Z1)
+	SCHED(320, 128, 832, 448)
+	ROUND4(Z1, Z2, Z3, Z4, Z0)
+	SCHED(384, 192, 896, 512)
+	ROUND4(Z0, Z1, Z2, Z3, Z4)
+	SCHED(448, 256, 960, 576)
+	ROUND4(Z4, Z0, Z1, Z2, Z3)
+	SCHED(512, 320, 0, 640)
+	ROUND4(Z3, Z4, Z0, Z1, Z2)
+	SCHED(576, 384, 64, 704)
+	ROUND4(Z2, Z3, Z4, Z0, Z1)
+	SCHED(640, 448, 128, 768)
+	ROUND4(Z1, Z2, Z3, Z4, Z0)
+	SCHED(704, 512, 192, 832)
+	ROUND4(Z0, Z1, Z2, Z3, Z4)
+	SCHED(768, 576, 256, 896)
+	ROUND4(Z4, Z0, Z1, Z2, Z3)
+	SCHED(832, 640, 320, 960)
+	ROUND4(Z3, Z4, Z0, Z1, Z2)
+	SCHED(896, 704, 384, 0)
+	ROUND4(Z2, Z3, Z4, Z0, Z1)
+	SCHED(960, 768, 448, 64)
+	ROUND4(Z1, Z2, Z3, Z4, Z0)
+
+	VPADDD    0(SI), Z0, Z0
+	VMOVDQU32 Z0, 0(SI)
+	VPADDD    64(SI), Z1, Z1
+	VMOVDQU32 Z1, 64(SI)
+	VPADDD    128(SI), Z2, Z2
+	VMOVDQU32 Z2, 128(SI)
+	VPADDD    192(SI), Z3, Z3
+	VMOVDQU32 Z3, 192(SI)
+	VPADDD    256(SI), Z4, Z4
+	VMOVDQU32 Z4, 256(SI)
+
+	VZEROUPPER
+	RET
@@ -0,0 +1,160 @@
+package vanity
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// maxLanes is the largest number of lanes of any backend.
+const maxLanes = 16
+
+// sha1Backend hashes a block in each of several lanes at once.
+type sha1Backend struct {
+	name  string
+	lanes int
+
+	// block updates the states h with the blocks w, in the first lanes lanes.
+	// Both are word-major, each word a row of maxLanes lanes, and w is
+	// overwritten with the message schedule. It is nil for the scalar backend.
+	block func(h *[5][maxLanes]uint32, w *[16][maxLanes]uint32)
+
+	// optIn is set for a backend only used when asked for by name, as it is
+	// yet to be tested on the hardware it is for
+	optIn bool
+}
+
+// scalarBackend hashes one iteration at a time, reusing the work before the
+// changing word of the number across iterations.
+var scalarBackend = &sha1Backend{name: "scalar", lanes: 1}
+
+// genericBackend hashes several iterations at once in pure Go. It is slower
+// than the scalar backend, and there as a reference for the others.
+var genericBackend = &sha1Backend{name: "generic", lanes: genericLanes, block: sha1BlockLanesGeneric}
+
+// genericLanes is the number of lanes of the generic backend.
+const genericLanes = 4
+
+// sha1Backends are the backends available on this machine, the default first
+// and the opt-in ones last.
+var sha1Backends []*sha1Backend
+
+// failedBackends are the names of the backends supported by the CPU that were
+// left out for hashing wrongly on it.
+var failedBackends []string
+
+func init() {
+	var optIn []*sha1Backend
+
+	for _, b := range simdBackends() {
+		switch {
+		case !b.works():
+			failedBackends = append(failedBackends, b.name)
+		case b.optIn:
+			optIn = append(optIn, b)
+		default:
+			sha1Backends = append(sha1Backends, b)
+		}
+	}
+
+	sha1Backends = append(sha1Backends, scalarBackend, genericBackend)
+	sha1Backends = append(sha1Backends, optIn...)
+}
+
+// Backends returns the names of the hashing backends available on this
+// machine, the default first and those only used when asked for last.
+func Backends() []string {
+	var names []string
+	for _, b := range sha1Backends {
+		names = append(names, b.name)
+	}
+	return names
+}
+
+// FailedBackends returns the names of the backends supported by the CPU that
+// are not available for failing their check against the scalar block function
+// on it, which points to a bug in the backend worth reporting.
+func FailedBackends() []string {
+	return failedBackends
+}
+
+// lookupBackend returns the backend with the given name, or the default one if
+// the name is empty.
+func lookupBackend(name string) (*sha1Backend, error) {
+	if name == "" {
+		return sha1Backends[0], nil
+	}
+
+	for _, b := range sha1Backends {
+		if b.name == name {
+			return b, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown backend %q (available: %s)", name, strings.Join(Backends(), ", "))
+}
+
+// works reports whether the backend hashes like the scalar block function,
+// so that a broken kernel on a CPU it was never run on is left unused rather
+// than silently missing matches.
+func (b *sha1Backend) works() bool {
+	var h [5][maxLanes]uint32
+	var w [16][maxLanes]uint32
+	var want [maxLanes][5]uint32
+
+	x := uint32(1)
+	next := func() uint32 {
+		x = x*1664525 + 1013904223
+		return x
+	}
+
+	for lane := range b.lanes {
+		var block [sha1.BlockSize]byte
+		for i := range 16 {
+			w[i][lane] = next()
+			binary.BigEndian.PutUint32(block[i*4:], w[i][lane])
+		}
+
+		var state [5]uint32
+		for k := range 5 {
+			state[k] = next()
+			h[k][lane] = state[k]
+		}
+
+		want[lane] = sha1Block(state, &block)
+	}
+
+	b.block(&h, &w)
+
+	for lane := range b.lanes {
+		for k := range 5 {
+			if h[k][lane] != want[lane][k] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// sha1BlockLanesGeneric is the block function of the generic backend.
+func sha1BlockLanesGeneric(h *[5][maxLanes]uint32, w *[16][maxLanes]uint32) {
+	for lane := range genericLanes {
+		var block [sha1.BlockSize]byte
+		for i := range 16 {
+			binary.BigEndian.PutUint32(block[i*4:], w[i][lane])
+		}
+
+		var state [5]uint32
+		for k := range 5 {
+			state[k] = h[k][lane]
+		}
+
+		state = sha1Block(state, &block)
+
+		for k := range 5 {
+			h[k][lane] = state[k]
+		}
+	}
+}
@@ -0,0 +1,44 @@
+//go:build !purego
+
+package vanity
+
+//go:noescape
+func sha1BlockAVX2(h *[5][maxLanes]uint32, w *[16][maxLanes]uint32)
+
+//go:noescape
+func sha1BlockAVX512(h *[5][maxLanes]uint32, w *[16][maxLanes]uint32)
+
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+func xgetbv() (eax, edx uint32)
+
+// simdBackends returns the SIMD backends supported by the CPU, fastest first.
+func simdBackends() []*sha1Backend {
+	var backends []*sha1Backend
+
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 7 {
+		return nil
+	}
+
+	// the OS must save the registers used, as reported by XGETBV
+	_, _, ecx1, _ := cpuid(1, 0)
+	if ecx1&(1<<27) == 0 {
+		return nil
+	}
+
+	xcr0, _ := xgetbv()
+	_, ebx7, _, _ := cpuid(7, 0)
+
+	// opmask, upper ZMM and high ZMM state, and AVX-512F
+	if xcr0&0xe6 == 0xe6 && ebx7&(1<<16) != 0 {
+		backends = append(backends, &sha1Backend{name: "avx512", lanes: 16, block: sha1BlockAVX512})
+	}
+
+	// SSE and AVX state, AVX and AVX2
+	if xcr0&0x6 == 0x6 && ecx1&(1<<28) != 0 && ebx7&(1<<5) != 0 {
+		backends = append(backends, &sha1Backend{name: "avx2", lanes: 8, block: sha1BlockAVX2})
+	}
+
+	return backends
+}
@@ -0,0 +1,44 @@
+//go:build !purego
+
+package vanity
+
+import (
+	"encoding/binary"
+	"os"
+)
+
+//go:noescape
+func sha1BlockNEON(h *[5][maxLanes]uint32, w *[16][maxLanes]uint32)
+
+// simdBackends returns the SIMD backends supported by the CPU, fastest first.
+// NEON is opt-in until its tests run on arm64 hardware in CI.
+func simdBackends() []*sha1Backend {
+	if !hasASIMD() {
+		return nil
+	}
+	return []*sha1Backend{{name: "neon", lanes: 4, block: sha1BlockNEON, optIn: true}}
+}
+
+// hasASIMD reports whether the CPU has Advanced SIMD (NEON), as found in the
+// hardware capabilities the kernel passes in the auxiliary vector on Linux.
+// Elsewhere, every arm64 CPU Go runs on has it.
+func hasASIMD() bool {
+	const (
+		atHWCAP    = 16
+		hwcapASIMD = 1 << 1
+	)
+
+	auxv, err := os.ReadFile("/proc/self/auxv")
+	if err != nil {
+		return true
+	}
+
+	// the vector is pairs of a key and a value, ending with a zero key
+	for ; len(auxv) >= 16; auxv = auxv[16:] {
+		if binary.LittleEndian.Uint64(auxv) == atHWCAP {
+			return binary.LittleEndian.Uint64(auxv[8:])&hwcapASIMD != 0
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,9 @@
+//go:build (!amd64 && !arm64) || purego
+
+package vanity
+
+// simdBackends returns the SIMD backends supported by the CPU, of which there
+// are none here.
+func simdBackends() []*sha1Backend {
+	return nil
+}
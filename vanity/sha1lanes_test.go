@@ -0,0 +1,95 @@
+package vanity
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestBackends(t *testing.T) {
+	backends := Backends()
+
+	// the pure Go ones come last but for those only used when asked for
+	var used []string
+	for _, b := range sha1Backends {
+		if !b.optIn {
+			used = append(used, b.name)
+		}
+	}
+
+	if len(used) < 2 || used[len(used)-2] != "scalar" || used[len(used)-1] != "generic" {
+		t.Fatalf("backends are %q, want the pure Go ones last", backends)
+	}
+
+	for _, name := range backends {
+		if b, err := lookupBackend(name); err != nil || b.name != name {
+			t.Errorf("lookupBackend(%q) = %v, %v", name, b, err)
+		}
+	}
+
+	if b, err := lookupBackend(""); err != nil || b.name != backends[0] || b.optIn {
+		t.Errorf("lookupBackend(\"\") = %v, %v, want %s", b, err, backends[0])
+	}
+
+	if failed := FailedBackends(); len(failed) > 0 {
+		t.Errorf("backends %q supported by the CPU failed their check", failed)
+	}
+}
+
+func TestBackendWorks(t *testing.T) {
+	broken := &sha1Backend{name: "broken", lanes: 4, block: func(h *[5][maxLanes]uint32, w *[16][maxLanes]uint32) {
+		sha1BlockLanesGeneric(h, w)
+		h[2][3]++
+	}}
+
+	if broken.works() {
+		t.Error("broken backend works")
+	}
+
+	if !genericBackend.works() {
+		t.Error("generic backend does not work")
+	}
+}
+
+// TestSHA1BlockLanes tests every backend the CPU supports, rather than those
+// available, so that a kernel left out for failing its check fails the test.
+func TestSHA1BlockLanes(t *testing.T) {
+	for _, backend := range append(simdBackends(), genericBackend) {
+		t.Run(backend.name, func(t *testing.T) {
+			r := rand.New(rand.NewPCG(1, 2))
+
+			for n := range 1000 {
+				var h [5][maxLanes]uint32
+				var w [16][maxLanes]uint32
+				var want [maxLanes][5]uint32
+
+				for lane := range backend.lanes {
+					var state [5]uint32
+					for k := range state {
+						state[k] = r.Uint32()
+						h[k][lane] = state[k]
+					}
+
+					var block [sha1.BlockSize]byte
+					for i := range w {
+						w[i][lane] = r.Uint32()
+						binary.BigEndian.PutUint32(block[i*4:], w[i][lane])
+					}
+
+					want[lane] = sha1Block(state, &block)
+				}
+
+				backend.block(&h, &w)
+
+				for lane := range backend.lanes {
+					for k := range 5 {
+						if got := h[k][lane]; got != want[lane][k] {
+							t.Fatalf("[%d] lane %d word %d is %08x, want %08x", n, lane, k, got, want[lane][k])
+						}
+					}
+				}
+			}
+		})
+	}
+}
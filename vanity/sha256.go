@@ -0,0 +1,131 @@
+package vanity
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/bits"
+)
+
+// sha256Hasher hashes an object holding the iteration number with SHA-256, for
+// repositories in FormatSHA256. As commitHasher does with SHA-1, it hashes the
+// blocks before the one holding the last digit of the number only when they
+// change, though it hashes that block and any after it in full.
+type sha256Hasher struct {
+	objectBuffer
+
+	// lastSum is the state after the whole blocks of the prefix
+	lastSum [8]uint32
+
+	// last is the offset in buf of the block holding the last digit, and pre
+	// the state before it
+	last int
+	pre  [8]uint32
+
+	// sum is the hash at the current iteration, if hashed
+	sum    [8]uint32
+	hashed bool
+}
+
+// newSHA256Hasher returns a hasher for the object with the iteration numbers
+// in the given layout.
+func newSHA256Hasher(l *layout) *sha256Hasher {
+	return &sha256Hasher{objectBuffer: newObjectBuffer(l)}
+}
+
+// advance moves the hasher to iteration n, given that it was at iteration
+// n-step.
+func (c *sha256Hasher) advance(n, step int) {
+	c.hashed = false
+
+	changed, laidOut := c.objectBuffer.advance(n, step)
+
+	if laidOut {
+		c.lastSum = sha256Blocks(sha256Init, c.whole())
+
+		lastDigit := c.lastDigit()
+		c.last = lastDigit - lastDigit%sha256.BlockSize
+		c.pre = sha256Blocks(c.lastSum, c.buf[:c.last])
+
+		return
+	}
+
+	// the blocks before the one with the last digit change only on carries
+	if changed < c.last {
+		c.pre = sha256Blocks(c.lastSum, c.buf[:c.last])
+	}
+}
+
+// word returns word k of the hash of the object at the current iteration. Only
+// the last rounds of SHA-256 could be skipped for a single word, so unlike
+// commitHasher it hashes the object in full, leaving hash nothing more to do.
+func (c *sha256Hasher) word(k int) uint32 {
+	return c.hash()[k]
+}
+
+// hash returns the hash of the object at the current iteration. The returned
+// sum is valid until the hasher is advanced.
+func (c *sha256Hasher) hash() []uint32 {
+	if !c.hashed {
+		c.sum = sha256Blocks(c.pre, c.buf[c.last:])
+		c.hashed = true
+	}
+	return c.sum[:]
+}
+
+// hex returns the hash last returned by hash in hex.
+func (c *sha256Hasher) hex() string {
+	return sumHex(c.sum[:])
+}
+
+// sha256Init is the SHA-256 state before any block has been hashed.
+var sha256Init = [8]uint32{0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19}
+
+var sha256K = [64]uint32{
+	0x428a2f98, 0x71374491, 0xb5c0fbcf, 0xe9b5dba5, 0x3956c25b, 0x59f111f1, 0x923f82a4, 0xab1c5ed5,
+	0xd807aa98, 0x12835b01, 0x243185be, 0x550c7dc3, 0x72be5d74, 0x80deb1fe, 0x9bdc06a7, 0xc19bf174,
+	0xe49b69c1, 0xefbe4786, 0x0fc19dc6, 0x240ca1cc, 0x2de92c6f, 0x4a7484aa, 0x5cb0a9dc, 0x76f988da,
+	0x983e5152, 0xa831c66d, 0xb00327c8, 0xbf597fc7, 0xc6e00bf3, 0xd5a79147, 0x06ca6351, 0x14292967,
+	0x27b70a85, 0x2e1b2138, 0x4d2c6dfc, 0x53380d13, 0x650a7354, 0x766a0abb, 0x81c2c92e, 0x92722c85,
+	0xa2bfe8a1, 0xa81a664b, 0xc24b8b70, 0xc76c51a3, 0xd192e819, 0xd6990624, 0xf40e3585, 0x106aa070,
+	0x19a4c116, 0x1e376c08, 0x2748774c, 0x34b0bcb5, 0x391c0cb3, 0x4ed8aa4a, 0x5b9cca4f, 0x682e6ff3,
+	0x748f82ee, 0x78a5636f, 0x84c87814, 0x8cc70208, 0x90befffa, 0xa4506ceb, 0xbef9a3f7, 0xc67178f2,
+}
+
+// sha256Block returns the SHA-256 state h updated with the given block.
+func sha256Block(h [8]uint32, block *[sha256.BlockSize]byte) [8]uint32 {
+	var w [64]uint32
+	for i := range 16 {
+		w[i] = binary.BigEndian.Uint32(block[i*4:])
+	}
+	for i := 16; i < 64; i++ {
+		s0 := bits.RotateLeft32(w[i-15], -7) ^ bits.RotateLeft32(w[i-15], -18) ^ w[i-15]>>3
+		s1 := bits.RotateLeft32(w[i-2], -17) ^ bits.RotateLeft32(w[i-2], -19) ^ w[i-2]>>10
+		w[i] = w[i-16] + s0 + w[i-7] + s1
+	}
+
+	a, b, c, d, e, f, g, hh := h[0], h[1], h[2], h[3], h[4], h[5], h[6], h[7]
+
+	for i := range 64 {
+		s1 := bits.RotateLeft32(e, -6) ^ bits.RotateLeft32(e, -11) ^ bits.RotateLeft32(e, -25)
+		ch := e&f ^ ^e&g
+		t1 := hh + s1 + ch + sha256K[i] + w[i]
+
+		s0 := bits.RotateLeft32(a, -2) ^ bits.RotateLeft32(a, -13) ^ bits.RotateLeft32(a, -22)
+		maj := a&b ^ a&c ^ b&c
+		t2 := s0 + maj
+
+		a, b, c, d, e, f, g, hh = t1+t2, a, b, c, d+t1, e, f, g
+	}
+
+	return [8]uint32{h[0] + a, h[1] + b, h[2] + c, h[3] + d, h[4] + e, h[5] + f, h[6] + g, h[7] + hh}
+}
+
+// sha256Blocks returns the SHA-256 state h updated with the given blocks. The
+// length of p must be a multiple of the block size.
+func sha256Blocks(h [8]uint32, p []byte) [8]uint32 {
+	for len(p) >= sha256.BlockSize {
+		h = sha256Block(h, (*[sha256.BlockSize]byte)(p))
+		p = p[sha256.BlockSize:]
+	}
+	return h
+}
@@ -0,0 +1,128 @@
+package vanity
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestSHA256Block(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+
+	for n := range 1000 {
+		message := make([]byte, r.IntN(4*sha256.BlockSize))
+		for i := range message {
+			message[i] = byte(r.Uint32())
+		}
+
+		// hash the whole blocks first and the rest padded, as sha256Hasher
+		// does, continuing from the midstate in between
+		whole := len(message) - len(message)%sha256.BlockSize
+
+		midstate := sha256Blocks(sha256Init, message[:whole])
+
+		sum := sha256Blocks(midstate, paddedNSizeTailBlock(message[whole:], 0, nil, len(message)))
+
+		var gotSum [sha256.Size]byte
+		for i, w := range sum {
+			binary.BigEndian.PutUint32(gotSum[i*4:], w)
+		}
+
+		if wantSum := sha256.Sum256(message); gotSum != wantSum {
+			t.Fatalf("[%d] got %x, want %x for %d bytes", n, gotSum, wantSum, len(message))
+		}
+	}
+}
+
+// sha256Hash returns the SHA-256 hash of the given object of the given type in
+// hex.
+func sha256Hash(typ string, object []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(fmt.Appendf(nil, "%s %d\x00%s", typ, len(object), object)))
+}
+
+func TestSHA256Hasher(t *testing.T) {
+	for _, tc := range testLayouts() {
+		t.Run(tc.name, func(t *testing.T) {
+			l, err := newLayout(tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the steps cross digit lengths, rebuilding the padded block, and
+			// carry into earlier blocks
+			for _, step := range []int{1, 7, 997} {
+				c := newSHA256Hasher(l)
+
+				for n := 0; n < 200*step; n += step {
+					c.advance(n, step)
+
+					sum := [8]uint32(c.hash())
+
+					if got, want := c.hex(), sha256Hash(TypeCommit, c.commit()); got != want {
+						t.Fatalf("[%d, %d] hash is %s, want %s", step, n, got, want)
+					}
+
+					if got, want := c.commit(), l.commitAt(n); !bytes.Equal(got, want) {
+						t.Fatalf("[%d, %d] commit is %q, want %q", step, n, got, want)
+					}
+
+					for k := range sum {
+						if got, want := c.word(k), sum[k]; got != want {
+							t.Errorf("[%d, %d] word(%d) = %08x, want %08x", step, n, k, got, want)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSearchSHA256(t *testing.T) {
+	for _, tc := range []struct {
+		typ    string
+		object string
+	}{
+		{TypeCommit, commit},
+		{TypeTree, treeEntry("100644", "a", strings.Repeat("01", sha256.Size))},
+	} {
+		t.Run(tc.typ, func(t *testing.T) {
+			s, err := NewSearcher(Options{
+				Commit:  []byte(tc.object),
+				Type:    tc.typ,
+				Key:     "c0ff",
+				Format:  FormatSHA256,
+				Targets: []Target{newTarget(AffixPatternFor(FormatSHA256, "c0f", "e"))},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := s.Search(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := len(res.Hash), 64; got != want {
+				t.Errorf("hash %q has %d digits, want %d", res.Hash, got, want)
+			}
+
+			if got, want := res.Hash, sha256Hash(tc.typ, res.Commit); got != want {
+				t.Errorf("hash = %q, want %q", got, want)
+			}
+
+			if !strings.HasPrefix(res.Hash, "c0f") || !strings.HasSuffix(res.Hash, "e") {
+				t.Errorf("hash %q does not start with c0f and end with e", res.Hash)
+			}
+
+			// the extra tree entry points to the empty blob by its SHA-256 hash
+			if tc.typ == TypeTree && !bytes.Contains(res.Commit, emptyBlob(FormatSHA256)) {
+				t.Errorf("new tree %q does not hold the empty blob", res.Commit)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package vanity
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var invalidKey = regexp.MustCompile(`^(commit|tree|parent|author|committer|encoding|object|type|tag|tagger)\b|[^a-zA-Z0-9]`).MatchString
+var validPrefix = regexp.MustCompile("^[0-9a-f]{1,64}$").MatchString
+var validPattern = regexp.MustCompile("^([0-9a-fx.]{40}|[0-9a-fx.]{64})$").MatchString
+
+// ValidKey reports whether key can be used as the key of the commit header
+// holding the iteration number. It must be alphanumeric and must not be that
+// of a standard commit header.
+func ValidKey(key string) bool {
+	return key != "" && !invalidKey(key)
+}
+
+// ValidPrefix reports whether s is a valid hash prefix or suffix: 1 to 64
+// lowercase hex digits, of which a SHA-1 hash has 40.
+func ValidPrefix(s string) bool {
+	return validPrefix(s)
+}
+
+// ValidPattern reports whether s is a valid hash pattern: 40 characters of
+// lowercase hex, '.' or 'x', the latter two matching any digit, or 64 for
+// SHA-256.
+func ValidPattern(s string) bool {
+	return validPattern(s)
+}
+
+// AffixPattern returns the SHA-1 hash pattern matching the given prefix and
+// suffix, either of which may be empty.
+func AffixPattern(hashPrefix, hashSuffix string) string {
+	return AffixPatternFor(FormatSHA1, hashPrefix, hashSuffix)
+}
+
+// AffixPatternFor returns the hash pattern matching the given prefix and
+// suffix in the given object format.
+func AffixPatternFor(format, hashPrefix, hashSuffix string) string {
+	wildcards := max(HashDigits(format)-len(hashPrefix)-len(hashSuffix), 0)
+	return hashPrefix + strings.Repeat(".", wildcards) + hashSuffix
+}
+
+// hashPatternWords returns the desired hash pattern as big-endian words and
+// the mask selecting the significant bits of each. The pattern is a full-length
+// hash in hex, where '.' or 'x' matches any nibble.
+func hashPatternWords(pattern string) (words, mask [8]uint32) {
+	for i := range min(len(pattern), sha256.Size*2) {
+		var v byte
+
+		switch c := pattern[i]; {
+		case c >= '0' && c <= '9':
+			v = c - '0'
+		case c >= 'a' && c <= 'f':
+			v = c - 'a' + 10
+		default:
+			continue
+		}
+
+		shift := 28 - 4*(i%8)
+		words[i/8] |= uint32(v) << shift
+		mask[i/8] |= 0xf << shift
+	}
+
+	return words, mask
+}
+
+// Target is a desired hash given as big-endian words and the mask selecting
+// the significant bits of each, with room for the longest hash.
+type Target struct {
+	words, mask [8]uint32
+
+	// digits is the number of digits of the hashes it is for
+	digits int
+}
+
+// ParseTarget returns the target matching hashes with the given pattern, as
+// accepted by ValidPattern. The pattern must have at least one fixed digit.
+func ParseTarget(pattern string) (Target, error) {
+	if !ValidPattern(pattern) {
+		return Target{}, errors.New("invalid pattern (must be 40 or 64 characters of lowercase hex, '.' or 'x')")
+	}
+
+	t := newTarget(pattern)
+
+	if t.Bits() == 0 {
+		return Target{}, errors.New("pattern must have at least one fixed digit")
+	}
+
+	return t, nil
+}
+
+// newTarget returns a target matching hashes with the given pattern.
+func newTarget(pattern string) Target {
+	words, mask := hashPatternWords(pattern)
+	return Target{words: words, mask: mask, digits: len(pattern)}
+}
+
+// match reports whether the significant bits of sum are those of the target,
+// which is for hashes of its size.
+func (t *Target) match(sum []uint32) bool {
+	for i := range sum {
+		if sum[i]&t.mask[i] != t.words[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Bits returns the number of significant bits in the target.
+func (t *Target) Bits() int {
+	var n int
+	for _, m := range t.mask {
+		n += bits.OnesCount32(m)
+	}
+	return n
+}
+
+// matcher matches hashes against any number of targets. It puts the targets
+// in groups sharing significant bits of one word, and indexes each group on
+// those bits, so that most hashes are rejected by a single lookup in a small
+// filter per group. Targets mixing prefixes and suffixes, say, end up in
+// separate groups rather than in one with no bits in common.
+type matcher struct {
+	targets []Target
+	groups  []*targetGroup
+
+	// k is the index of the word used as key by all groups, if keyed, which
+	// the hashers compute first
+	k     int
+	keyed bool
+}
+
+// targetGroup is a group of targets indexed on the bits of word k that are
+// significant in all of them, keyMask.
+type targetGroup struct {
+	k       int
+	keyMask uint32
+
+	// filter has a bit set for each filterIndex of a target key
+	filter [1 << filterBits / 64]uint64
+
+	// index maps keys to the indices of the targets having them, in order
+	index map[uint32][]int
+}
+
+const filterBits = 16
+
+// filterIndex maps a key to a bit in a group filter.
+func filterIndex(key uint32) uint32 {
+	return key * 0x9e3779b1 >> (32 - filterBits)
+}
+
+func newMatcher(targets []Target) *matcher {
+	m := &matcher{targets: targets}
+
+	// each target joins the first group keyed on its word with the most
+	// significant bits, and with bits in common with it, narrowing the key
+	// to the bits in common
+	member := make([]*targetGroup, len(targets))
+
+	for i, t := range targets {
+		k, bestBits := 0, -1
+		for j, mask := range t.mask {
+			if b := bits.OnesCount32(mask); b > bestBits {
+				k, bestBits = j, b
+			}
+		}
+
+		for _, g := range m.groups {
+			if g.k == k && g.keyMask&t.mask[k] != 0 {
+				g.keyMask &= t.mask[k]
+				member[i] = g
+				break
+			}
+		}
+
+		if member[i] == nil {
+			member[i] = &targetGroup{k: k, keyMask: t.mask[k], index: make(map[uint32][]int)}
+			m.groups = append(m.groups, member[i])
+		}
+	}
+
+	for i, t := range targets {
+		g := member[i]
+		key := t.words[g.k] & g.keyMask
+		g.index[key] = append(g.index[key], i)
+		fi := filterIndex(key)
+		g.filter[fi/64] |= 1 << (fi % 64)
+	}
+
+	// with groups keyed on different words, computing each word would cost
+	// about as much as the whole hash
+	m.keyed = len(m.groups) > 0
+	for _, g := range m.groups {
+		if g.k != m.groups[0].k {
+			m.keyed = false
+		}
+	}
+
+	if m.keyed {
+		m.k = m.groups[0].k
+	}
+
+	return m
+}
+
+// candidate reports whether a hash having the given word k may match any of
+// the targets, which are keyed. Most hashes can be rejected on this word alone.
+func (m *matcher) candidate(word uint32) bool {
+	for _, g := range m.groups {
+		if g.candidate(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// match reports whether sum matches any of the targets, and if so the index of
+// the first one it matches.
+func (m *matcher) match(sum []uint32) (int, bool) {
+	matched, ok := 0, false
+
+	for _, g := range m.groups {
+		if !g.candidate(sum[g.k]) {
+			continue
+		}
+
+		for _, i := range g.index[sum[g.k]&g.keyMask] {
+			if ok && i >= matched {
+				break
+			}
+			if m.targets[i].match(sum) {
+				matched, ok = i, true
+				break
+			}
+		}
+	}
+
+	return matched, ok
+}
+
+// candidate reports whether a hash having the given word k may match any of
+// the targets of the group.
+func (g *targetGroup) candidate(word uint32) bool {
+	fi := filterIndex(word & g.keyMask)
+	return g.filter[fi/64]&(1<<(fi%64)) != 0
+}
+
+// probability returns the probability of a random hash matching any of the
+// targets, disregarding any overlap between them.
+func (m *matcher) probability() float64 {
+	var p float64
+	for _, t := range m.targets {
+		p += math.Pow(2, -float64(t.Bits()))
+	}
+	return min(p, 1)
+}
+
+// Scorer scores hashes by the number of leading bits or digits they share with
+// a hash of a single repeated digit. Higher scores are better.
+type Scorer struct {
+	// repeated is a word of the repeated digit
+	repeated uint32
+
+	// bits is the number of bits per point of score, and unit what a point is
+	bits int
+	unit string
+}
+
+// NewScorer returns a scorer for "zeros", scoring leading zero bits, or for a
+// lowercase hex digit, scoring leading such digits.
+func NewScorer(best string) (*Scorer, error) {
+	if best == "zeros" {
+		return &Scorer{repeated: 0, bits: 1, unit: "leading zero bits"}, nil
+	}
+
+	if len(best) != 1 || !validPrefix(best) {
+		return nil, errors.New("invalid best (must be \"zeros\" or a lowercase hex digit)")
+	}
+
+	d, _ := strconv.ParseUint(best, 16, 32)
+
+	return &Scorer{
+		repeated: uint32(d) * 0x11111111,
+		bits:     4,
+		unit:     fmt.Sprintf("leading %q digits", best),
+	}, nil
+}
+
+func (s *Scorer) score(sum []uint32) int {
+	var n int
+	for _, w := range sum {
+		z := bits.LeadingZeros32(w ^ s.repeated)
+		n += z
+		if z < 32 {
+			break
+		}
+	}
+	return n / s.bits
+}
+
+// Unit returns what a point of score is, such as "leading zero bits".
+func (s *Scorer) Unit() string {
+	return s.unit
+}
+
+// Describe describes the given score, such as "12 leading zero bits".
+func (s *Scorer) Describe(score int) string {
+	return fmt.Sprintf("%d %s", score, s.unit)
+}
@@ -0,0 +1,348 @@
+package vanity
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestInvalidKey(t *testing.T) {
+	for n, tc := range []struct {
+		key     string
+		invalid bool
+	}{
+		{"commit", true},
+		{"tree", true},
+		{"parent", true},
+		{"author", true},
+		{"committer", true},
+		{"encoding", true},
+		{"object", true},
+		{"type", true},
+		{"tag", true},
+		{"tagger", true},
+		{"commit ", true},
+		{"non-alphanumeric", true},
+		{"x", false},
+		{"f00", false},
+	} {
+		if got, want := invalidKey(tc.key), tc.invalid; got != want {
+			t.Errorf("[%d] invalidKey(%q) = %t, want %t", n, tc.key, got, want)
+		}
+	}
+}
+
+func TestValidPattern(t *testing.T) {
+	for n, tc := range []struct {
+		pattern string
+		valid   bool
+	}{
+		{"", false},
+		{"c0ffee", false},
+		{"c0ffee..................................", true},
+		{"c0ffeexxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx42", true},
+		{"C0FFEE..................................", false},
+		{"c0ffee.................................", false},   // 39 chars
+		{"c0ffee...................................", false}, // 41 chars
+		{"c0ffee" + strings.Repeat(".", 58), true},           // 64 chars
+		{"c0ffee" + strings.Repeat(".", 57), false},          // 63 chars
+		{"c0ffee" + strings.Repeat(".", 59), false},          // 65 chars
+		{"g.......................................", false},
+		{"*.......................................", false},
+	} {
+		if got, want := validPattern(tc.pattern), tc.valid; got != want {
+			t.Errorf("[%d] validPattern(%q) = %t, want %t", n, tc.pattern, got, want)
+		}
+	}
+}
+
+func TestValidPrefix(t *testing.T) {
+	for n, tc := range []struct {
+		prefix string
+		valid  bool
+	}{
+		{"", false},
+		{"x", false},
+		{"0", true},
+		{"f00", true},
+		{"0000000000000000000000000000000000000000", true},                           // 40 chars
+		{"0000000000000000000000000000000000000000000000000000000000000000", true},   // 64 chars
+		{"00000000000000000000000000000000000000000000000000000000000000000", false}, // 65 chars
+	} {
+		if got, want := validPrefix(tc.prefix), tc.valid; got != want {
+			t.Errorf("[%d] validPrefix(%q) = %t, want %t", n, tc.prefix, got, want)
+		}
+	}
+}
+
+func TestHashPatternWords(t *testing.T) {
+	for n, tc := range []struct {
+		pattern   string
+		wantWords [8]uint32
+		wantMask  [8]uint32
+	}{
+		{AffixPattern("0", ""), [8]uint32{}, [8]uint32{0xf0000000}},
+		{AffixPattern("c0ffee", ""), [8]uint32{0xc0ffee00}, [8]uint32{0xffffff00}},
+		{AffixPattern("c0ffeebeef", ""), [8]uint32{0xc0ffeebe, 0xef000000}, [8]uint32{0xffffffff, 0xff000000}},
+		{AffixPattern("", "0"), [8]uint32{}, [8]uint32{4: 0x0000000f}},
+		{AffixPattern("", "c0ffee"), [8]uint32{4: 0x00c0ffee}, [8]uint32{4: 0x00ffffff}},
+		{AffixPattern("", "c0ffeebeef"), [8]uint32{3: 0x000000c0, 4: 0xffeebeef}, [8]uint32{3: 0x000000ff, 4: 0xffffffff}},
+		{
+			"c0ffee..........x.....xxxxxxxxxxxxxxxx42",
+			[8]uint32{0xc0ffee00, 4: 0x00000042},
+			[8]uint32{0xffffff00, 4: 0x000000ff},
+		},
+		{
+			".0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0",
+			[8]uint32{},
+			[8]uint32{0x0f0f0f0f, 0x0f0f0f0f, 0x0f0f0f0f, 0x0f0f0f0f, 0x0f0f0f0f},
+		},
+		{
+			"0123456789abcdef0123456789abcdef01234567",
+			[8]uint32{0x01234567, 0x89abcdef, 0x01234567, 0x89abcdef, 0x01234567},
+			[8]uint32{0xffffffff, 0xffffffff, 0xffffffff, 0xffffffff, 0xffffffff},
+		},
+	} {
+		words, mask := hashPatternWords(tc.pattern)
+
+		if words != tc.wantWords {
+			t.Errorf("[%d] words = %08x, want %08x", n, words, tc.wantWords)
+		}
+
+		if mask != tc.wantMask {
+			t.Errorf("[%d] mask = %08x, want %08x", n, mask, tc.wantMask)
+		}
+	}
+}
+
+func TestAffixPattern(t *testing.T) {
+	for n, tc := range []struct {
+		prefix, suffix string
+		want           string
+	}{
+		{"c0ffee", "", "c0ffee.................................."},
+		{"", "beef", "....................................beef"},
+		{"c0ffee", "beef", "c0ffee..............................beef"},
+		{"0123456789abcdef0123456789abcdef01234567", "", "0123456789abcdef0123456789abcdef01234567"},
+	} {
+		if got, want := AffixPattern(tc.prefix, tc.suffix), tc.want; got != want {
+			t.Errorf("[%d] AffixPattern(%q, %q) = %q, want %q", n, tc.prefix, tc.suffix, got, want)
+		}
+	}
+}
+
+func TestTargetMatch(t *testing.T) {
+	sum := [5]uint32{0xc0ffee00, 0x11111111, 0x22222222, 0x33333333, 0x4444beef}
+
+	for n, tc := range []struct {
+		prefix, suffix string
+		want           bool
+	}{
+		{"c0ffee", "", true},
+		{"", "beef", true},
+		{"c0ffee", "beef", true},
+		{"c0ffee001111111122222222333333334444beef", "", true},
+		{"c0ffee", "dead", false},
+		{"decade", "beef", false},
+		{"c0ffef", "", false},
+		{"", "4beef", true},
+		{"", "5beef", false},
+	} {
+		target := newTarget(AffixPattern(tc.prefix, tc.suffix))
+
+		if got, want := target.match(sum[:]), tc.want; got != want {
+			t.Errorf("[%d] newTarget(AffixPattern(%q, %q)).match(%08x) = %t, want %t", n, tc.prefix, tc.suffix, sum, got, want)
+		}
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	var targets []Target
+
+	for _, prefix := range []string{"c0ffee", "decade", "facade", "c0ffeebeef", "0"} {
+		targets = append(targets, newTarget(AffixPattern(prefix, "")))
+	}
+
+	targets = append(targets, newTarget(AffixPattern("", "beef")))
+
+	m := newMatcher(targets)
+
+	for n, tc := range []struct {
+		sum         [5]uint32
+		wantMatched int
+		wantOK      bool
+	}{
+		{[5]uint32{0xc0ffee00}, 0, true},
+		{[5]uint32{0xdecade12}, 1, true},
+		{[5]uint32{0xfacade34, 0xffffffff}, 2, true},
+		{[5]uint32{0xc0ffeebe, 0xef000000}, 0, true},
+		{[5]uint32{0x01234567}, 4, true},
+		{[5]uint32{0x12345678, 4: 0x0000beef}, 5, true},
+		{[5]uint32{0xc0ffef00}, 0, false},
+		{[5]uint32{0xdecad000}, 0, false},
+		{[5]uint32{0x12345678}, 0, false},
+	} {
+		matched, ok := m.match(tc.sum[:])
+
+		if got, want := ok, tc.wantOK; got != want {
+			t.Errorf("[%d] match(%08x) ok = %t, want %t", n, tc.sum, got, want)
+		}
+
+		if got, want := matched, tc.wantMatched; got != want {
+			t.Errorf("[%d] match(%08x) matched = %d, want %d", n, tc.sum, got, want)
+		}
+	}
+}
+
+func TestMatcherGroups(t *testing.T) {
+	for n, tc := range []struct {
+		patterns   []string
+		wantGroups int
+		wantKeyed  bool
+	}{
+		{[]string{AffixPattern("c0ffee", ""), AffixPattern("decade", "")}, 1, true},
+		{[]string{AffixPattern("c0ffee", ""), AffixPattern("c0ffeebeef", ""), AffixPattern("0", "")}, 1, true},
+		{[]string{AffixPattern("c0ffee", ""), AffixPattern("", "beef")}, 2, false},
+		{[]string{"c0ffee..................................", "......ee................................"}, 2, true},
+		{[]string{AffixPattern("c0ffee", ""), AffixPattern("", "beef"), AffixPattern("decade", ""), AffixPattern("", "f00d")}, 2, false},
+	} {
+		var targets []Target
+		for _, pattern := range tc.patterns {
+			targets = append(targets, newTarget(pattern))
+		}
+
+		m := newMatcher(targets)
+
+		if got, want := len(m.groups), tc.wantGroups; got != want {
+			t.Errorf("[%d] %d groups, want %d", n, got, want)
+		}
+
+		if got, want := m.keyed, tc.wantKeyed; got != want {
+			t.Errorf("[%d] keyed = %t, want %t", n, got, want)
+		}
+
+		for _, g := range m.groups {
+			if g.keyMask == 0 {
+				t.Errorf("[%d] group on word %d has no key bits", n, g.k)
+			}
+		}
+	}
+}
+
+func TestMatcherRandom(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+
+	// prefixes and suffixes, which share no word, in separate groups
+	var targets []Target
+
+	for i := range 50 {
+		affix := fmt.Sprintf("%06x", r.Uint32N(1<<24))
+		if i%2 == 0 {
+			targets = append(targets, newTarget(AffixPattern(affix, "")))
+		} else {
+			targets = append(targets, newTarget(AffixPattern("", affix)))
+		}
+	}
+
+	m := newMatcher(targets)
+
+	for range 100000 {
+		var sum [5]uint32
+		for i := range sum {
+			sum[i] = r.Uint32()
+		}
+
+		// give every other sum the prefix or suffix of a target
+		if r.IntN(2) == 0 {
+			t := targets[r.IntN(len(targets))]
+			for k := range sum {
+				sum[k] = sum[k]&^t.mask[k] | t.words[k]
+			}
+		}
+
+		wantMatched, wantOK := 0, false
+		for i, t := range targets {
+			if t.match(sum[:]) {
+				wantMatched, wantOK = i, true
+				break
+			}
+		}
+
+		matched, ok := m.match(sum[:])
+
+		if ok != wantOK || matched != wantMatched {
+			t.Fatalf("match(%08x) = %d, %t; want %d, %t", sum, matched, ok, wantMatched, wantOK)
+		}
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	for n, tc := range []struct {
+		pattern string
+		valid   bool
+	}{
+		{AffixPattern("c0ffee", ""), true},
+		{AffixPattern("", "c0ffee"), true},
+		{"c0ffee", false},
+		{AffixPattern("", ""), false},
+		{AffixPattern("C0FFEE", ""), false},
+	} {
+		if _, err := ParseTarget(tc.pattern); (err == nil) != tc.valid {
+			t.Errorf("[%d] ParseTarget(%q) error = %v, want valid %t", n, tc.pattern, err, tc.valid)
+		}
+	}
+}
+
+func TestTargetBits(t *testing.T) {
+	for n, tc := range []struct {
+		prefix, suffix string
+		want           int
+	}{
+		{"0", "", 4},
+		{"", "0", 4},
+		{"c0ffee", "beef", 40},
+		{"0000000000000000000000000000000000000000", "", 160},
+	} {
+		target := newTarget(AffixPattern(tc.prefix, tc.suffix))
+
+		if got, want := target.Bits(), tc.want; got != want {
+			t.Errorf("[%d] newTarget(AffixPattern(%q, %q)).Bits() = %d, want %d", n, tc.prefix, tc.suffix, got, want)
+		}
+	}
+}
+
+func TestScorer(t *testing.T) {
+	for n, tc := range []struct {
+		best string
+		sum  [5]uint32
+		want int
+	}{
+		{"zeros", [5]uint32{0xffffffff}, 0},
+		{"zeros", [5]uint32{0x7fffffff}, 1},
+		{"zeros", [5]uint32{0x0000ffff}, 16},
+		{"zeros", [5]uint32{0x00000000, 0x0fffffff}, 36},
+		{"zeros", [5]uint32{}, 160},
+		{"0", [5]uint32{0x0000ffff}, 4},
+		{"0", [5]uint32{0x00000000, 0x0fffffff}, 9},
+		{"f", [5]uint32{0xfff00000}, 3},
+		{"f", [5]uint32{0x0fffffff}, 0},
+		{"c", [5]uint32{0xcccccccc, 0xccc0ffee}, 11},
+		{"c", [5]uint32{0xcccccccc, 0xcccccccc, 0xcccccccc, 0xcccccccc, 0xcccccccc}, 40},
+	} {
+		s, err := NewScorer(tc.best)
+		if err != nil {
+			t.Fatalf("[%d] NewScorer(%q): %v", n, tc.best, err)
+		}
+
+		if got, want := s.score(tc.sum[:]), tc.want; got != want {
+			t.Errorf("[%d] NewScorer(%q).score(%08x) = %d, want %d", n, tc.best, tc.sum, got, want)
+		}
+	}
+
+	for _, best := range []string{"", "zero", "F", "g", "ff"} {
+		if _, err := NewScorer(best); err == nil {
+			t.Errorf("NewScorer(%q) returned no error", best)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package vanity
+
+import (
+	"context"
+	"time"
+)
+
+// throttleSlice is how long a throttled worker works before sleeping.
+const throttleSlice = 10 * time.Millisecond
+
+// throttle keeps a worker busy for only a share of the time, by having it
+// sleep in proportion to the time it has worked since it last slept.
+type throttle struct {
+	percent int
+	resumed time.Time
+}
+
+// newThrottle returns a throttle keeping a worker busy for the given
+// percentage of the time, or nil if there is no limit.
+func newThrottle(percent int) *throttle {
+	if percent <= 0 || percent >= 100 {
+		return nil
+	}
+	return &throttle{percent: percent, resumed: time.Now()}
+}
+
+// pause sleeps if the worker has worked for long enough since it last slept,
+// waking early if ctx or done is done.
+func (t *throttle) pause(ctx context.Context, done <-chan struct{}) {
+	busy := time.Since(t.resumed)
+	if busy < throttleSlice {
+		return
+	}
+
+	timer := time.NewTimer(t.sleep(busy))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	case <-done:
+	}
+
+	t.resumed = time.Now()
+}
+
+// sleep returns how long to sleep after having worked for busy.
+func (t *throttle) sleep(busy time.Duration) time.Duration {
+	return busy * time.Duration(100-t.percent) / time.Duration(t.percent)
+}
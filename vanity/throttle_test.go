@@ -0,0 +1,58 @@
+package vanity
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNewThrottle(t *testing.T) {
+	for _, percent := range []int{0, 100} {
+		if th := newThrottle(percent); th != nil {
+			t.Errorf("newThrottle(%d) = %v, want nil", percent, th)
+		}
+	}
+}
+
+func TestThrottleSleep(t *testing.T) {
+	for n, tc := range []struct {
+		percent int
+		busy    time.Duration
+		want    time.Duration
+	}{
+		{50, 10 * time.Millisecond, 10 * time.Millisecond},
+		{25, 10 * time.Millisecond, 30 * time.Millisecond},
+		{75, 30 * time.Millisecond, 10 * time.Millisecond},
+		{1, 10 * time.Millisecond, 990 * time.Millisecond},
+		{99, 99 * time.Millisecond, time.Millisecond},
+	} {
+		if got := newThrottle(tc.percent).sleep(tc.busy); got != tc.want {
+			t.Errorf("[%d] sleep(%s) at %d%% = %s, want %s", n, tc.busy, tc.percent, got, tc.want)
+		}
+	}
+}
+
+func TestThrottlePause(t *testing.T) {
+	th := newThrottle(1)
+
+	start := time.Now()
+
+	th.pause(context.Background(), nil)
+
+	if elapsed := time.Since(start); elapsed > throttleSlice {
+		t.Errorf("paused for %s before having worked for %s", elapsed, throttleSlice)
+	}
+
+	th.resumed = time.Now().Add(-throttleSlice)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start = time.Now()
+
+	th.pause(ctx, nil)
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("paused for %s after ctx was done", elapsed)
+	}
+}
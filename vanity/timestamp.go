@@ -0,0 +1,138 @@
+package vanity
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// timeLayout returns the layout for NonceTimestamp. Within a run, the
+// committer timestamp, or that of the tagger of a tag, goes through the
+// window. The runs move the author timestamp through the window too if
+// allowed, and then add whitespace at the end of the message if allowed. The
+// committer timestamp is kept at or after the author timestamp.
+func timeLayout(opts Options) (*layout, error) {
+	window := int(opts.TimeWindow / time.Second)
+	if window <= 0 {
+		return nil, errors.New("time window must be at least a second")
+	}
+
+	head, tail, err := headTail(opts.Commit)
+	if err != nil {
+		return nil, err
+	}
+
+	committer := "committer"
+	if opts.Type == TypeTag {
+		if opts.TimeAuthor {
+			return nil, errors.New("tags have no author timestamp to move")
+		}
+		committer = "tagger"
+	}
+
+	cStart, cEnd, committed, err := timestamp(head, committer)
+	if err != nil {
+		return nil, err
+	}
+
+	// tags have no author, and so no author timestamp to keep after
+	var aStart, aEnd, authored int
+	if opts.Type != TypeTag {
+		if aStart, aEnd, authored, err = timestamp(head, "author"); err != nil {
+			return nil, err
+		}
+
+		if opts.TimeAuthor && aEnd > cStart {
+			return nil, errors.New("cannot parse commit: committer line comes before author line")
+		}
+	}
+
+	// the committer timestamp is not moved before the author timestamp, as
+	// git tools take a commit committed before it was authored as suspect,
+	// unless the commit was so to begin with
+	base := max(committed-window, min(authored, committed), 0)
+	size := committed + window + 1 - base
+
+	// nor is the author timestamp moved after the earliest committer one
+	authorBase, authorSize := authored, 1
+	if opts.TimeAuthor {
+		authorBase = max(authored-window, 0)
+		authorSize = min(authored+window, max(base, authored)) + 1 - authorBase
+	}
+
+	if authorSize > math.MaxInt/size {
+		return nil, errors.New("time window too large")
+	}
+
+	message, final := tail, []byte(nil)
+	if opts.TimeWhitespace {
+		if message, final, err = splitMessage(tail); err != nil {
+			return nil, err
+		}
+		message = slices.Concat([]byte("\n\n"), message)
+	}
+
+	l := &layout{
+		alphabet: decimal,
+		base:     base,
+		size:     size,
+		end:      size * authorSize,
+	}
+
+	if opts.TimeWhitespace {
+		l.end = 0
+	}
+
+	l.split = func(run int) (before, after []byte) {
+		before = head[:cStart]
+		if opts.TimeAuthor {
+			before = slices.Concat(head[:aStart], strconv.AppendInt(nil, int64(authorBase+run%authorSize), 10), head[aEnd:cStart])
+		}
+		after = slices.Concat(head[cEnd:], message)
+
+		// the first pass through the window has no whitespace, the next ones
+		// have the numbers from zero up
+		if n := run / authorSize; n > 0 {
+			after = whitespace.append(after, n-1)
+		}
+
+		return before, append(after, final...)
+	}
+
+	return l, nil
+}
+
+// timestamp returns the offsets in head of the timestamp of the given header,
+// author, committer or tagger, and its value.
+func timestamp(head []byte, header string) (start, end, t int, err error) {
+	for line := range bytes.Lines(head) {
+		if !bytes.HasPrefix(line, []byte(header+" ")) {
+			start += len(line)
+			continue
+		}
+
+		// the line ends with the email, the timestamp and the time zone, as
+		// in "<author@example.com> 1577872800 +0000"
+		email := bytes.LastIndexByte(line, '>')
+		if email == -1 {
+			break
+		}
+
+		rest := bytes.TrimPrefix(line[email+1:], []byte(" "))
+		digits, _, ok := bytes.Cut(rest, []byte(" "))
+
+		t, err = strconv.Atoi(string(digits))
+		if !ok || err != nil || t < 0 {
+			break
+		}
+
+		start += len(line) - len(rest)
+		return start, start + len(digits), t, nil
+	}
+
+	return 0, 0, 0, fmt.Errorf("cannot parse commit: no %s timestamp", header)
+}
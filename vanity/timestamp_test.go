@@ -0,0 +1,235 @@
+package vanity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimestamp(t *testing.T) {
+	head, _, err := headTail([]byte(commit))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n, tc := range []struct {
+		header string
+		want   string
+		wantT  int
+	}{
+		{"author", "1577872800", 1577872800},
+		{"committer", "1577876400", 1577876400},
+	} {
+		start, end, ts, err := timestamp(head, tc.header)
+		if err != nil {
+			t.Fatalf("[%d] error: %v", n, err)
+		}
+
+		if got := string(head[start:end]); got != tc.want {
+			t.Errorf("[%d] timestamp is %q, want %q", n, got, tc.want)
+		}
+
+		if ts != tc.wantT {
+			t.Errorf("[%d] timestamp is %d, want %d", n, ts, tc.wantT)
+		}
+	}
+
+	for n, head := range []string{
+		"tree 0000000000000000000000000000000000000000",
+		"committer Committer Name <committer@example.com>",
+		"committer Committer Name <committer@example.com> +0100",
+		"committer Committer Name <committer@example.com> -1 +0100",
+		"committer Committer Name committer@example.com 1577876400 +0100",
+	} {
+		if _, _, _, err := timestamp([]byte(head), "committer"); err == nil {
+			t.Errorf("[%d] got no error", n)
+		}
+	}
+}
+
+func TestTimeLayout(t *testing.T) {
+	const head = "tree 0000000000000000000000000000000000000000\n" +
+		"author Author Name <author@example.com> %s +0000\n" +
+		"committer Committer Name <committer@example.com> %s +0100"
+
+	at := func(author, committer, message string) string {
+		return fmt.Sprintf(head, author, committer) + "\n\n" + message
+	}
+
+	opts := Options{Commit: []byte(commit), Nonce: NonceTimestamp, TimeWindow: 2 * time.Second}
+
+	for n, tc := range []struct {
+		author, whitespace bool
+		iteration          int
+		want               string
+		wantEnd            int
+	}{
+		{false, false, 0, at("1577872800", "1577876398", "Message\n"), 5},
+		{false, false, 2, at("1577872800", "1577876400", "Message\n"), 5},
+		{false, false, 4, at("1577872800", "1577876402", "Message\n"), 5},
+		{true, false, 4, at("1577872798", "1577876402", "Message\n"), 25},
+		{true, false, 5, at("1577872799", "1577876398", "Message\n"), 25},
+		{true, false, 24, at("1577872802", "1577876402", "Message\n"), 25},
+		{false, true, 4, at("1577872800", "1577876402", "Message\n"), 0},
+		{false, true, 5, at("1577872800", "1577876398", "Message \n"), 0},
+		{false, true, 10, at("1577872800", "1577876398", "Message\t\n"), 0},
+		{false, true, 15, at("1577872800", "1577876398", "Message\t \n"), 0},
+		{true, true, 25, at("1577872798", "1577876398", "Message \n"), 0},
+	} {
+		opts.TimeAuthor, opts.TimeWhitespace = tc.author, tc.whitespace
+
+		l, err := newLayout(opts)
+		if err != nil {
+			t.Fatalf("[%d] error: %v", n, err)
+		}
+
+		if got, want := string(l.commitAt(tc.iteration)), tc.want; got != want {
+			t.Errorf("[%d] commit is:\n%s\n\nwant:\n%s", n, got, want)
+		}
+
+		if got, want := l.end, tc.wantEnd; got != want {
+			t.Errorf("[%d] end = %d, want %d", n, got, want)
+		}
+	}
+}
+
+func TestTimeLayoutAuthorFirst(t *testing.T) {
+	const head = "tree 0000000000000000000000000000000000000000\n" +
+		"author Author Name <author@example.com> %d +0000\n" +
+		"committer Committer Name <committer@example.com> %d +0100\n\nMessage\n"
+
+	for n, tc := range []struct {
+		author, committer int
+		timeAuthor        bool
+	}{
+		{1577876400, 1577876400, false},
+		{1577876400, 1577876400, true},
+		{1577876399, 1577876400, false},
+		{1577876399, 1577876400, true},
+		{1577872800, 1577876400, true},
+		{1577876401, 1577876400, false},
+		{1577876401, 1577876400, true},
+	} {
+		l, err := newLayout(Options{
+			Commit:     fmt.Appendf(nil, head, tc.author, tc.committer),
+			Nonce:      NonceTimestamp,
+			TimeWindow: 2 * time.Second,
+			TimeAuthor: tc.timeAuthor,
+		})
+		if err != nil {
+			t.Fatalf("[%d] error: %v", n, err)
+		}
+
+		if l.end <= 0 {
+			t.Fatalf("[%d] end = %d, want more than 0", n, l.end)
+		}
+
+		for i := range l.end {
+			commit := l.commitAt(i)
+
+			_, _, author, err := timestamp(commit, "author")
+			if err != nil {
+				t.Fatalf("[%d, %d] error: %v", n, i, err)
+			}
+
+			_, _, committer, err := timestamp(commit, "committer")
+			if err != nil {
+				t.Fatalf("[%d, %d] error: %v", n, i, err)
+			}
+
+			// a commit committed before it was authored is never made more so
+			if author > committer && author-committer > tc.author-tc.committer {
+				t.Errorf("[%d, %d] author %d is after committer %d", n, i, author, committer)
+			}
+		}
+	}
+}
+
+func TestTimeLayoutErrors(t *testing.T) {
+	for n, opts := range []Options{
+		{Commit: []byte(commit), Nonce: NonceTimestamp},
+		{Commit: []byte(commit), Nonce: NonceTimestamp, TimeWindow: time.Millisecond},
+		{Commit: []byte("tree 0000000000000000000000000000000000000000\n\nMessage\n"), Nonce: NonceTimestamp, TimeWindow: time.Second},
+		{Commit: []byte(commit[:strings.Index(commit, "Message")]), Nonce: NonceTimestamp, TimeWindow: time.Second, TimeWhitespace: true},
+	} {
+		if _, err := newLayout(opts); err == nil {
+			t.Errorf("[%d] got no error", n)
+		}
+	}
+}
+
+func TestTimeLayoutCommitterLineFirst(t *testing.T) {
+	const head = "tree 0000000000000000000000000000000000000000\n" +
+		"committer Committer Name <committer@example.com> 1577876400 +0100\n" +
+		"author Author Name <author@example.com> 1577872800 +0000\n\nMessage\n"
+
+	_, err := newLayout(Options{Commit: []byte(head), Nonce: NonceTimestamp, TimeWindow: time.Second, TimeAuthor: true})
+
+	if got, want := fmt.Sprint(err), "cannot parse commit: committer line comes before author line"; got != want {
+		t.Errorf("got error %q, want %q", got, want)
+	}
+}
+
+func TestSearchTimestamp(t *testing.T) {
+	for _, backend := range Backends() {
+		t.Run(backend, func(t *testing.T) {
+			s, err := NewSearcher(Options{
+				Commit:     []byte(commit),
+				Nonce:      NonceTimestamp,
+				TimeWindow: time.Hour,
+				Targets:    []Target{newTarget(AffixPattern("c0f", ""))},
+				Backend:    backend,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := s.End(), 7201; got != want {
+				t.Errorf("end = %d, want %d", got, want)
+			}
+
+			res, err := s.Search(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := res.Hash, commitHash(res.Commit); got != want {
+				t.Errorf("hash = %q, want %q", got, want)
+			}
+
+			if !strings.HasPrefix(res.Hash, "c0f") {
+				t.Errorf("hash %q does not start with c0f", res.Hash)
+			}
+
+			want := strings.Replace(commit, "1577876400", strconv.Itoa(1577872800+res.Iteration), 1)
+
+			if got := string(res.Commit); got != want {
+				t.Errorf("new commit is:\n%s\n\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestSearchTimestampExhausted(t *testing.T) {
+	s, err := NewSearcher(Options{
+		Commit:     []byte(commit),
+		Nonce:      NonceTimestamp,
+		TimeWindow: 10 * time.Second,
+		Targets:    []Target{newTarget(AffixPattern("c0ffee", ""))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Search(context.Background()); !errors.Is(err, ErrExhausted) {
+		t.Errorf("got error %v, want %v", err, ErrExhausted)
+	}
+
+	if got, want := s.Tested(), 21; got != want {
+		t.Errorf("tested %d, want %d", got, want)
+	}
+}
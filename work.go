@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/jsageryd/git-vanity-commit/vanity"
+)
+
+const (
+	// heartbeatInterval is how often a worker tells the coordinator how far it
+	// has come, and asks for work when there is none.
+	heartbeatInterval = 2 * time.Second
+
+	// retryInterval is how long a worker waits before trying to reach the
+	// coordinator again, for at most retryTimeout.
+	retryInterval = 2 * time.Second
+	retryTimeout  = time.Minute
+)
+
+// work runs the work subcommand with the given arguments.
+func work(args []string) {
+	flags := flag.NewFlagSet("git-vanity-commit work", flag.ExitOnError)
+
+	coordinatorURL := flags.String("coordinator", "", "URL of the coordinator started with serve, as in http://host:8080")
+	workers := flags.Int("workers", 0, "Number of concurrent workers (defaults to one per CPU)")
+	backend := flags.String("backend", "", "Hashing backend to use, one of those run by bench (defaults to the fastest that works on this CPU)")
+	cpuPercent := flags.Int("cpu-percent", 100, "Percentage of the time of a CPU each worker may use, sleeping in between")
+	nice := flags.Int("nice", 0, niceUsage)
+	quiet := flags.Bool("quiet", false, "Suppress log output")
+
+	flags.Parse(args)
+
+	if *coordinatorURL == "" {
+		fmt.Fprintln(os.Stderr, "missing coordinator")
+		fmt.Fprintln(os.Stderr)
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	if *workers < 0 {
+		fmt.Fprintln(os.Stderr, "number of workers must be positive")
+		fmt.Fprintln(os.Stderr)
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	if *backend != "" && !slices.Contains(vanity.Backends(), *backend) {
+		fmt.Fprintf(os.Stderr, "unknown backend %q (available: %s)\n", *backend, strings.Join(vanity.Backends(), ", "))
+		fmt.Fprintln(os.Stderr)
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	if *cpuPercent < 1 || *cpuPercent > 100 {
+		fmt.Fprintln(os.Stderr, "CPU percentage must be between 1 and 100")
+		fmt.Fprintln(os.Stderr)
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	if err := checkNice(*nice); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr)
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	if *quiet {
+		log.SetOutput(io.Discard)
+	}
+
+	if *nice > 0 {
+		if err := setNice(*nice); err != nil {
+			log.Fatalf("error setting niceness: %v", err)
+		}
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	w := &worker{
+		coordinator: strings.TrimSuffix(*coordinatorURL, "/"),
+		id:          fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		opts:        vanity.Options{Workers: *workers, Backend: *backend, CPUPercent: *cpuPercent},
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	log.Printf("Working for %s as %s", w.coordinator, w.id)
+	logFailedBackends()
+
+	if err := w.run(ctx); err != nil {
+		if ctx.Err() != nil {
+			log.Println("Interrupted")
+			os.Exit(130)
+		}
+		log.Fatal(err)
+	}
+
+	log.Println("No more work")
+}
+
+// worker searches ranges of iterations leased from a coordinator.
+type worker struct {
+	coordinator string
+	id          string
+
+	// opts are the options of each search, with the commit, key, nonce,
+	// targets, range, encoding and time window filled in from the lease
+	opts vanity.Options
+
+	client *http.Client
+}
+
+// run searches ranges leased from the coordinator until it has no more work,
+// or until ctx is done.
+func (w *worker) run(ctx context.Context) error {
+	for {
+		var resp leaseResponse
+
+		if err := w.post(ctx, "/lease", leaseRequest{Worker: w.id}, &resp); err != nil {
+			return err
+		}
+
+		if resp.Done {
+			return nil
+		}
+
+		if resp.Lease == nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(heartbeatInterval):
+			}
+			continue
+		}
+
+		if err := w.search(ctx, resp.Lease); err != nil {
+			return err
+		}
+	}
+}
+
+// search searches a leased range and reports the result, unless the
+// coordinator says to stop first.
+func (w *worker) search(ctx context.Context, l *lease) error {
+	var targets []vanity.Target
+
+	for _, pattern := range l.Targets {
+		t, err := vanity.ParseTarget(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid target from coordinator: %w", err)
+		}
+		targets = append(targets, t)
+	}
+
+	opts := w.opts
+	opts.Commit, opts.Key, opts.Nonce, opts.Targets = l.Commit, l.Key, l.Nonce, targets
+	opts.Start, opts.End = l.Start, l.End
+	opts.Type, opts.Line, opts.Format = l.Type, l.Line, l.Format
+	opts.Encoding, opts.Width = l.Encoding, l.Width
+	opts.TimeWindow, opts.TimeAuthor, opts.TimeWhitespace = l.TimeWindow, l.TimeAuthor, l.TimeWhitespace
+
+	s, err := vanity.NewSearcher(opts)
+	if err != nil {
+		return fmt.Errorf("invalid lease from coordinator: %w", err)
+	}
+
+	log.Printf("Searching iterations %d to %d", l.Start, l.End)
+
+	searchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var stopped atomic.Bool
+
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			var resp heartbeatResponse
+
+			// a missed heartbeat is made up for by the next one
+			if err := w.postOnce(searchCtx, "/heartbeat", heartbeat{Worker: w.id, Lease: l.ID, Tested: s.Tested()}, &resp); err != nil {
+				continue
+			}
+
+			if resp.Stop {
+				stopped.Store(true)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	res, err := s.Search(searchCtx)
+
+	close(done)
+	cancel()
+	wg.Wait()
+
+	rep := report{Worker: w.id, Lease: l.ID, Tested: s.Tested()}
+
+	switch {
+	case err == nil:
+		log.Printf("Found %s (iteration %d)", res.Hash, res.Iteration)
+		rep.Found, rep.Iteration = true, res.Iteration
+	case errors.Is(err, vanity.ErrExhausted):
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case stopped.Load():
+		log.Println("Stopped by the coordinator")
+		return nil
+	default:
+		return err
+	}
+
+	return w.post(ctx, "/report", rep, nil)
+}
+
+// post posts in as JSON to the given path of the coordinator, and decodes the
+// response into out unless it is nil. It retries for a while if the
+// coordinator cannot be reached.
+func (w *worker) post(ctx context.Context, path string, in, out any) error {
+	deadline := time.Now().Add(retryTimeout)
+
+	for {
+		err := w.postOnce(ctx, path, in, out)
+		if err == nil || ctx.Err() != nil || time.Now().After(deadline) {
+			return err
+		}
+
+		log.Printf("Error reaching coordinator (retrying): %v", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// postOnce posts in as JSON to the given path of the coordinator, and decodes
+// the response into out unless it is nil.
+func (w *worker) postOnce(ctx context.Context, path string, in, out any) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.coordinator+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s from coordinator: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}